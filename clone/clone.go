@@ -0,0 +1,296 @@
+// Package clone copies MIFARE Classic cards with an MFRC522 reader.
+//
+// A source card is captured into an Image with Capture, which can be stored (see Image.MarshalBinary)
+// and later written to a target card with Write. Write reads every block back and returns
+// a Report with the result for each block.
+package clone
+
+import (
+	"context"
+	"errors"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+)
+
+// DefaultKeys are commonly used MIFARE Classic keys, including the transport key and the
+// keys used by NFC Forum (MAD and NDEF) formatted cards.
+var DefaultKeys = [][]byte{
+	{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+	{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5},
+	{0xD3, 0xF7, 0xD3, 0xF7, 0xD3, 0xF7},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5},
+	{0x4D, 0x3A, 0x99, 0xC3, 0x51, 0xDD},
+	{0x1A, 0x98, 0x2C, 0x7E, 0x45, 0x9A},
+	{0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF},
+}
+
+// Options configure how an image is written to the target card.
+type Options struct {
+	// Keys are tried to authenticate the sectors of the target card, after the keys from the image.
+	// A blank target card usually uses the transport key (FF FF FF FF FF FF).
+	Keys mfrc522.KeyMap
+
+	// Block0 also writes the manufacturer block (block 0), which holds the UUID.
+	// Regular cards refuse this, so it only works on magic cards (see mfrc522.Session.WriteManufacturerBlock).
+	Block0 bool
+
+	// Password is the password of Gen4 magic cards, which is needed to write block 0.
+	// If nil, the default password is used.
+	Password []byte
+}
+
+// Capture selects the source card and reads it with the keys from the key map.
+// If the card is lost while reading, the blocks read until then are returned with the error.
+func Capture(r *mfrc522.MFRC522, keys mfrc522.KeyMap) (*Image, error) {
+	return CaptureContext(context.Background(), r, keys)
+}
+
+// CaptureContext is like Capture, but stops when the context is canceled or its deadline expires.
+func CaptureContext(ctx context.Context, r *mfrc522.MFRC522, keys mfrc522.KeyMap) (*Image, error) {
+	dump, err := r.DumpClassicContext(ctx, keys)
+	if dump == nil {
+		return nil, err
+	}
+
+	return &Image{ClassicDump: *dump}, err
+}
+
+// Write selects the target card and writes the image to it (see WriteSession).
+func Write(r *mfrc522.MFRC522, img *Image, opts Options) (*Report, error) {
+	return WriteContext(context.Background(), r, img, opts)
+}
+
+// WriteContext is like Write, but stops when the context is canceled or its deadline expires.
+func WriteContext(ctx context.Context, r *mfrc522.MFRC522, img *Image, opts Options) (*Report, error) {
+	s, err := r.SelectContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = s.Close() }()
+
+	return WriteSessionContext(ctx, s, img, opts)
+}
+
+// WriteSession writes the image to the selected target card and reads every written block back.
+//
+// The sector trailers are written last in each sector, since they change the keys of the sector.
+// A trailer is only written if both of its keys are known (key B is also known if the card
+// returned it), since writing an unknown key would lock the sector.
+//
+// An error is only returned if the target card can't hold the image or if the card is lost,
+// otherwise the result of each block is in the report.
+func WriteSession(s *mfrc522.Session, img *Image, opts Options) (*Report, error) {
+	return WriteSessionContext(context.Background(), s, img, opts)
+}
+
+// WriteSessionContext is like WriteSession, but stops when the context is canceled or its deadline
+// expires. The report holds the results of the blocks written until then.
+func WriteSessionContext(ctx context.Context, s *mfrc522.Session, img *Image, opts Options) (*Report, error) {
+	family := s.Card().Family()
+	if !family.Classic() {
+		return nil, errors.New("target is not a MIFARE Classic card: " + family.String())
+	}
+	if err := checkLayout(img.Blocks, img.Keys); err != nil {
+		return nil, err
+	}
+	if mfrc522.SectorFirstBlock(byte(family.Sectors())) < len(img.Blocks) {
+		return nil, errors.New("target card is too small for the image")
+	}
+
+	report := &Report{Blocks: make([]BlockResult, len(img.Blocks))}
+	w := writer{s: s}
+	for sector := range byte(len(img.Keys)) {
+		w.authMode, w.key = 0, nil
+
+		first := mfrc522.SectorFirstBlock(sector)
+		last := first + int(mfrc522.SectorBlocks(sector)) - 1
+		keys := targetKeys(img.Keys[sector], opts.Keys.Keys(sector))
+
+		for addr := first; addr <= last; addr++ {
+			report.Blocks[addr] = w.writeBlock(ctx, byte(addr), img, keys, addr == last, opts)
+
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			if s.Closed() {
+				return report, errors.New("lost the target card")
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// writer writes the blocks of an image to the target card.
+type writer struct {
+	s *mfrc522.Session
+
+	// authMode and key authenticated the current sector. They're tried first for the next block.
+	authMode byte
+	key      []byte
+}
+
+// writeBlock writes a block from the image and reads it back.
+func (w *writer) writeBlock(ctx context.Context, addr byte, img *Image, keys [][]byte, trailer bool, opts Options) BlockResult {
+	data := img.Blocks[addr]
+	if data == nil {
+		return BlockResult{Status: BlockSkipped, Err: errors.New("block was not read from the source card")}
+	}
+	if addr == 0 {
+		if !opts.Block0 {
+			return BlockResult{Status: BlockSkipped, Err: errors.New("manufacturer block is not written")}
+		}
+
+		return w.writeBlock0(ctx, data, keys, opts)
+	}
+
+	// The new keys are needed to read the trailer back
+	verifyKeys := keys
+	if trailer {
+		sectorKeys := img.Keys[mfrc522.BlockSector(addr)]
+		if sectorKeys.KeyA == nil {
+			return BlockResult{Status: BlockSkipped, Err: errors.New("key A of the sector is unknown")}
+		}
+		if sectorKeys.KeyB == nil && !keyBReadable(data) {
+			return BlockResult{Status: BlockSkipped, Err: errors.New("key B of the sector is unknown")}
+		}
+
+		verifyKeys = [][]byte{sectorKeys.KeyA}
+		if sectorKeys.KeyB != nil {
+			verifyKeys = append(verifyKeys, sectorKeys.KeyB)
+		}
+	}
+
+	if err := w.withSector(ctx, addr, keys, func() error {
+		return w.s.WriteBlockContext(ctx, addr, data)
+	}); err != nil {
+		return BlockResult{Status: BlockFailed, Err: err}
+	}
+
+	if trailer {
+		w.authMode, w.key = 0, nil
+	}
+
+	var read []byte
+	if err := w.withSector(ctx, addr, verifyKeys, func() error {
+		var err error
+		read, err = w.s.ReadBlockContext(ctx, addr)
+		return err
+	}); err != nil {
+		return BlockResult{Status: BlockUnverified, Err: err}
+	}
+
+	if !blockEqual(data, read, trailer) {
+		return BlockResult{Status: BlockMismatch, Err: mfrc522.ErrMismatch{Addr: addr}}
+	}
+
+	return BlockResult{Status: BlockVerified}
+}
+
+// writeBlock0 writes block 0 of a magic card and reads it back.
+func (w *writer) writeBlock0(ctx context.Context, data []byte, keys [][]byte, opts Options) BlockResult {
+	w.authMode, w.key = 0, nil
+
+	if err := w.s.WriteManufacturerBlockContext(ctx, data, mfrc522.MagicOptions{Keys: keys, Password: opts.Password}); err != nil {
+		return BlockResult{Status: BlockFailed, Err: err}
+	}
+
+	var read []byte
+	if err := w.withSector(ctx, 0, keys, func() error {
+		var err error
+		read, err = w.s.ReadBlockContext(ctx, 0)
+		return err
+	}); err != nil {
+		return BlockResult{Status: BlockUnverified, Err: err}
+	}
+
+	if !blockEqual(data, read, false) {
+		return BlockResult{Status: BlockMismatch, Err: mfrc522.ErrMismatch{Addr: 0}}
+	}
+
+	return BlockResult{Status: BlockVerified}
+}
+
+// withSector authenticates the sector of the block address and runs fn, trying every key
+// as key A and key B until fn succeeds. The key that worked last is tried first.
+func (w *writer) withSector(ctx context.Context, addr byte, keys [][]byte, fn func() error) error {
+	sector := mfrc522.BlockSector(addr)
+	trailer := byte(mfrc522.SectorFirstBlock(sector)) + mfrc522.SectorBlocks(sector) - 1
+
+	// An error from fn is more useful than a failed authentication with the next key
+	var fnErr error
+	try := func(authMode byte, key []byte) error {
+		if err := w.s.AuthenticateContext(ctx, authMode, trailer, key); err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			fnErr = err
+			return err
+		}
+
+		w.authMode, w.key = authMode, key
+
+		return nil
+	}
+
+	if w.key != nil {
+		if err := try(w.authMode, w.key); err == nil || w.s.Closed() {
+			return err
+		}
+	}
+
+	err := errors.New("no keys for the sector")
+	for _, authMode := range []byte{mfrc522.AuthKeyACmd, mfrc522.AuthKeyBCmd} {
+		for _, key := range keys {
+			if err = try(authMode, key); err == nil || w.s.Closed() {
+				return err
+			}
+		}
+	}
+
+	if fnErr != nil {
+		return fnErr
+	}
+
+	return err
+}
+
+// targetKeys returns the keys to try on the target card: the source card's keys
+// (in case the target was already written), followed by the keys from the options.
+func targetKeys(keys mfrc522.SectorKeys, extra [][]byte) [][]byte {
+	var all [][]byte
+	if keys.KeyA != nil {
+		all = append(all, keys.KeyA)
+	}
+	if keys.KeyB != nil {
+		all = append(all, keys.KeyB)
+	}
+
+	return append(all, extra...)
+}
+
+// keyBReadable reports whether the access bits in the trailer allow reading key B,
+// in which case the card returns key B and it is part of the image.
+func keyBReadable(trailer []byte) bool {
+	c1 := trailer[7] >> 7 & 1
+	c2 := trailer[8] >> 3 & 1
+	c3 := trailer[8] >> 7 & 1
+
+	// Key B is readable for the access conditions 000, 010 and 001 (C1 C2 C3)
+	return c1 == 0 && (c2 == 0 || c3 == 0)
+}
+
+// blockEqual reports whether the block was read back as written. The card never returns
+// key A of a trailer, and only returns key B if it is readable.
+func blockEqual(written, read []byte, trailer bool) bool {
+	if !trailer {
+		return string(written) == string(read)
+	}
+
+	if string(written[6:10]) != string(read[6:10]) {
+		return false
+	}
+
+	return string(written[10:]) == string(read[10:]) || string(read[10:]) == string(make([]byte, 6))
+}
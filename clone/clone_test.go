@@ -0,0 +1,192 @@
+package clone_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/msthtrifork/gorfid/clone"
+	"github.com/msthtrifork/gorfid/mfrc522"
+	"github.com/msthtrifork/gorfid/mfrc522/sim"
+)
+
+// sourceKey is key A of sector 1 of the source card, which is not one of the default keys.
+var sourceKey = []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+// newSource creates a 1K source card with some data and a non-default key in sector 1.
+func newSource(t *testing.T) *sim.Classic {
+	t.Helper()
+
+	card, err := sim.NewClassic(sim.Classic1K, []byte{0xDE, 0xAD, 0xBE, 0xEF})
+	if err != nil {
+		t.Fatalf("NewClassic() error = %v", err)
+	}
+	for block := 1; block < 64; block++ {
+		if block%4 != 3 {
+			card.SetBlock(block, [16]byte{byte(block), 0xAA, 0x55})
+		}
+	}
+	card.SetTrailer(1, sourceKey, sim.TransportAccessBits, sim.DefaultKey)
+
+	return card
+}
+
+// capture reads the source card into an image and passes it through MarshalBinary.
+func capture(t *testing.T, src *sim.Classic, keys mfrc522.KeyMap) *clone.Image {
+	t.Helper()
+
+	c := sim.NewChip()
+	c.Add(src)
+	m, err := mfrc522.New(c, c.RST(), c.IRQ(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	img, err := clone.Capture(m, keys)
+	if err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+	if !img.Complete() {
+		t.Fatal("Capture() returned an incomplete image")
+	}
+
+	data, err := img.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	var decoded clone.Image
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	return &decoded
+}
+
+// write writes the image to the target card.
+func write(t *testing.T, dst *sim.Classic, img *clone.Image, opts clone.Options) *clone.Report {
+	t.Helper()
+
+	c := sim.NewChip()
+	c.Add(dst)
+	m, err := mfrc522.New(c, c.RST(), c.IRQ(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	report, err := clone.Write(m, img, opts)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	return report
+}
+
+func TestCloneMagic(t *testing.T) {
+	keys := mfrc522.KeyMap{Common: append(clone.DefaultKeys, sourceKey)}
+
+	tests := []struct {
+		name  string
+		magic sim.Magic
+	}{
+		{"Gen1a", sim.MagicGen1a},
+		{"Gen2", sim.MagicGen2},
+		{"Gen3", sim.MagicGen3},
+		{"Gen4", sim.MagicGen4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := newSource(t)
+			img := capture(t, src, keys)
+
+			dst, err := sim.NewClassic(sim.Classic1K, []byte{0x01, 0x02, 0x03, 0x04})
+			if err != nil {
+				t.Fatalf("NewClassic() error = %v", err)
+			}
+			dst.SetMagic(tt.magic)
+
+			report := write(t, dst, img, clone.Options{Keys: keys, Block0: true})
+			if !report.OK() || report.Count(clone.BlockVerified) != 64 {
+				t.Errorf("Write() verified %d blocks, OK: %t, want all 64",
+					report.Count(clone.BlockVerified), report.OK())
+			}
+			if !bytes.Equal(dst.Dump(), src.Dump()) {
+				t.Error("target card differs from the source card")
+			}
+		})
+	}
+}
+
+func TestCloneGenuine(t *testing.T) {
+	keys := mfrc522.KeyMap{Common: append(clone.DefaultKeys, sourceKey)}
+	src := newSource(t)
+	img := capture(t, src, keys)
+
+	tests := []struct {
+		name   string
+		block0 bool
+		status clone.BlockStatus
+	}{
+		{"without block 0", false, clone.BlockSkipped},
+		{"with block 0", true, clone.BlockFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst, err := sim.NewClassic(sim.Classic1K, []byte{0x01, 0x02, 0x03, 0x04})
+			if err != nil {
+				t.Fatalf("NewClassic() error = %v", err)
+			}
+			block0 := dst.Block(0)
+
+			report := write(t, dst, img, clone.Options{Keys: keys, Block0: tt.block0})
+			if report.Blocks[0].Status != tt.status {
+				t.Errorf("block 0 status = %v, want %v", report.Blocks[0].Status, tt.status)
+			}
+			if report.Count(clone.BlockVerified) != 63 {
+				t.Errorf("Write() verified %d blocks, want 63", report.Count(clone.BlockVerified))
+			}
+			if dst.Block(0) != block0 {
+				t.Error("block 0 of the genuine card changed")
+			}
+
+			dump := dst.Dump()
+			if !bytes.Equal(dump[16:], src.Dump()[16:]) {
+				t.Error("target card differs from the source card after block 0")
+			}
+		})
+	}
+}
+
+func TestCloneUnknownKey(t *testing.T) {
+	// Without the key of sector 1, its blocks are not captured and not written
+	src := newSource(t)
+	c := sim.NewChip()
+	c.Add(src)
+	m, err := mfrc522.New(c, c.RST(), c.IRQ(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	img, err := clone.Capture(m, mfrc522.KeyMap{Common: clone.DefaultKeys})
+	if err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+	if img.Complete() {
+		t.Fatal("Capture() returned a complete image without the key of sector 1")
+	}
+
+	dst, err := sim.NewClassic(sim.Classic1K, []byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic() error = %v", err)
+	}
+	report := write(t, dst, img, clone.Options{Keys: mfrc522.KeyMap{Common: clone.DefaultKeys}})
+	for addr := 4; addr < 8; addr++ {
+		if report.Blocks[addr].Status != clone.BlockSkipped {
+			t.Errorf("block %d status = %v, want skipped", addr, report.Blocks[addr].Status)
+		}
+	}
+	if !report.OK() {
+		t.Error("OK() = false, want true with only skipped blocks")
+	}
+}
@@ -0,0 +1,148 @@
+package clone
+
+import (
+	"errors"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+)
+
+// imageVersion is the version of the binary image format.
+const imageVersion = 1
+
+// Image is a captured MIFARE Classic card.
+// It holds the UUID, ATQA and SAK of the card, every block that could be read,
+// and the keys that opened each sector.
+type Image struct {
+	mfrc522.ClassicDump
+}
+
+// MarshalBinary encodes the image, so it can be stored until it is written to a card.
+//
+// The format starts with a version byte, followed by the UUID length, UUID, ATQA (low byte first)
+// and SAK. Then come the number of blocks (2 bytes, big-endian) and, for every block, a byte that
+// is 1 if the block was read, followed by its 16 bytes. The image ends with the number of sectors
+// and, for every sector, a byte with bit 0 set if key A is known and bit 1 set if key B is known,
+// followed by the known keys.
+func (img *Image) MarshalBinary() ([]byte, error) {
+	card := img.Card
+	data := []byte{imageVersion, byte(len(card.UUID))}
+	data = append(data, card.UUID...)
+	data = append(data, byte(card.ATQA), byte(card.ATQA>>8), card.SAK)
+
+	data = append(data, byte(len(img.Blocks)>>8), byte(len(img.Blocks)))
+	for _, block := range img.Blocks {
+		if block == nil {
+			data = append(data, 0)
+			continue
+		}
+		if len(block) != 16 {
+			return nil, errors.New("invalid block length, expected 16 bytes")
+		}
+
+		data = append(data, 1)
+		data = append(data, block...)
+	}
+
+	data = append(data, byte(len(img.Keys)))
+	for _, keys := range img.Keys {
+		var flags byte
+		if keys.KeyA != nil {
+			flags |= 0x01
+		}
+		if keys.KeyB != nil {
+			flags |= 0x02
+		}
+
+		data = append(data, flags)
+		data = append(data, keys.KeyA...)
+		data = append(data, keys.KeyB...)
+	}
+
+	return data, nil
+}
+
+// UnmarshalBinary decodes an image encoded by MarshalBinary.
+func (img *Image) UnmarshalBinary(data []byte) error {
+	r := reader{data: data}
+
+	if r.byte() != imageVersion {
+		return errors.New("unsupported image version")
+	}
+
+	var card mfrc522.CardInfo
+	card.UUID = r.bytes(int(r.byte()))
+	card.ATQA = uint16(r.byte()) | uint16(r.byte())<<8
+	card.SAK = r.byte()
+
+	blocks := make([][]byte, int(r.byte())<<8|int(r.byte()))
+	for i := range blocks {
+		if r.byte() == 1 {
+			blocks[i] = r.bytes(16)
+		}
+	}
+
+	keys := make([]mfrc522.SectorKeys, r.byte())
+	for i := range keys {
+		flags := r.byte()
+		if flags&0x01 != 0 {
+			keys[i].KeyA = r.bytes(6)
+		}
+		if flags&0x02 != 0 {
+			keys[i].KeyB = r.bytes(6)
+		}
+	}
+
+	if r.short {
+		return errors.New("image is truncated")
+	}
+	if err := checkLayout(blocks, keys); err != nil {
+		return err
+	}
+
+	img.Card = card
+	img.Blocks = blocks
+	img.Keys = keys
+
+	return nil
+}
+
+// checkLayout returns an error if the blocks don't cover exactly the sectors of the keys.
+func checkLayout(blocks [][]byte, keys []mfrc522.SectorKeys) error {
+	// A MIFARE Classic 4K card has the most sectors
+	if len(keys) > mfrc522.FamilyClassic4K.Sectors() ||
+		len(blocks) != mfrc522.SectorFirstBlock(byte(len(keys))) {
+		return errors.New("number of blocks doesn't match the number of sectors")
+	}
+
+	return nil
+}
+
+// reader reads an encoded image and remembers if it ran out of data.
+type reader struct {
+	data  []byte
+	short bool
+}
+
+// byte returns the next byte, or 0 if there is no data left.
+func (r *reader) byte() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+
+	return b[0]
+}
+
+// bytes returns a copy of the next n bytes, or nil if there is not enough data left.
+func (r *reader) bytes(n int) []byte {
+	if len(r.data) < n {
+		r.data = nil
+		r.short = true
+		return nil
+	}
+
+	b := append([]byte(nil), r.data[:n]...)
+	r.data = r.data[n:]
+
+	return b
+}
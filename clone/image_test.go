@@ -0,0 +1,124 @@
+package clone_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/msthtrifork/gorfid/clone"
+	"github.com/msthtrifork/gorfid/mfrc522"
+	"github.com/msthtrifork/gorfid/mfrc522/sim"
+)
+
+// newImage creates an image of a 1K card with every other block read, and some keys known.
+func newImage() *clone.Image {
+	img := &clone.Image{ClassicDump: mfrc522.ClassicDump{
+		Card:   mfrc522.CardInfo{UUID: []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, ATQA: 0x0044, SAK: 0x08},
+		Blocks: make([][]byte, 64),
+		Keys:   make([]mfrc522.SectorKeys, 16),
+	}}
+	for addr := 0; addr < 64; addr += 2 {
+		img.Blocks[addr] = bytes.Repeat([]byte{byte(addr)}, 16)
+	}
+	img.Keys[0].KeyA = sim.DefaultKey
+	img.Keys[1].KeyB = []byte{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5}
+	img.Keys[2] = mfrc522.SectorKeys{KeyA: sim.DefaultKey, KeyB: sim.DefaultKey}
+
+	return img
+}
+
+func TestImageMarshalBinary(t *testing.T) {
+	img := newImage()
+	data, err := img.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	var got clone.Image
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	if !bytes.Equal(got.Card.UUID, img.Card.UUID) || got.Card.ATQA != img.Card.ATQA || got.Card.SAK != img.Card.SAK {
+		t.Errorf("UnmarshalBinary().Card = %+v, want %+v", got.Card, img.Card)
+	}
+	for addr := range img.Blocks {
+		if (got.Blocks[addr] == nil) != (img.Blocks[addr] == nil) || !bytes.Equal(got.Blocks[addr], img.Blocks[addr]) {
+			t.Errorf("block %d = % x, want % x", addr, got.Blocks[addr], img.Blocks[addr])
+		}
+	}
+	for sector, keys := range img.Keys {
+		if !bytes.Equal(got.Keys[sector].KeyA, keys.KeyA) || !bytes.Equal(got.Keys[sector].KeyB, keys.KeyB) {
+			t.Errorf("Keys[%d] = %+v, want %+v", sector, got.Keys[sector], keys)
+		}
+	}
+}
+
+func TestImageUnmarshalBinaryErrors(t *testing.T) {
+	data, err := newImage().MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	// The number of sectors is the byte before the 16 sectors, 2 of which have one key and one has two
+	sectors := len(data) - 16 - 4*6 - 1
+
+	tests := []struct {
+		name   string
+		modify func([]byte) []byte
+	}{
+		{"empty", func([]byte) []byte { return nil }},
+		{"version", func(d []byte) []byte { d[0] = 2; return d }},
+		{"truncated", func(d []byte) []byte { return d[:len(d)-1] }},
+		{"fewer sectors", func(d []byte) []byte { d[sectors] = 15; return d[:len(d)-1] }},
+		{"too many sectors", func(d []byte) []byte { d[sectors] = 17; return append(d, 0) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var img clone.Image
+			if err := img.UnmarshalBinary(tt.modify(bytes.Clone(data))); err == nil {
+				t.Error("UnmarshalBinary() succeeded")
+			}
+		})
+	}
+}
+
+func TestWriteSessionInvalidImage(t *testing.T) {
+	c := sim.NewChip()
+	card, err := sim.NewClassic(sim.Classic4K, []byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic() error = %v", err)
+	}
+	c.Add(card)
+	m, err := mfrc522.New(c, c.RST(), c.IRQ(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	s, err := m.Select()
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	tests := []struct {
+		name         string
+		blocks, keys int
+	}{
+		{"more blocks", 64, 15},
+		{"more sectors", 60, 16},
+		{"more sectors than a 4K card", 256, 41},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := &clone.Image{ClassicDump: mfrc522.ClassicDump{
+				Blocks: make([][]byte, tt.blocks),
+				Keys:   make([]mfrc522.SectorKeys, tt.keys),
+			}}
+			if _, err := clone.WriteSession(s, img, clone.Options{}); err == nil {
+				t.Error("WriteSession() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package clone
+
+// BlockStatus is the result of writing a block to the target card.
+type BlockStatus byte
+
+// Block statuses
+const (
+	// BlockSkipped means the block was not written, because it was not read from the
+	// source card, or because it can't be written to the target card.
+	BlockSkipped BlockStatus = iota
+
+	// BlockVerified means the block was written and read back with the same content.
+	BlockVerified
+
+	// BlockFailed means the block could not be written.
+	BlockFailed
+
+	// BlockMismatch means the block was written, but was read back with a different content.
+	BlockMismatch
+
+	// BlockUnverified means the block was written, but could not be read back.
+	BlockUnverified
+)
+
+// String returns a short description of the status.
+func (s BlockStatus) String() string {
+	switch s {
+	case BlockSkipped:
+		return "skipped"
+	case BlockVerified:
+		return "verified"
+	case BlockFailed:
+		return "failed"
+	case BlockMismatch:
+		return "mismatch"
+	case BlockUnverified:
+		return "unverified"
+	default:
+		return "unknown"
+	}
+}
+
+// BlockResult is the result of writing a block to the target card.
+type BlockResult struct {
+	Status BlockStatus
+
+	// Err is the reason the block was skipped, failed or couldn't be verified.
+	Err error
+}
+
+// Report is the result of writing an image to a target card, with a result for every block.
+type Report struct {
+	Blocks []BlockResult
+}
+
+// OK reports whether every block was written and verified, other than the skipped ones.
+func (r *Report) OK() bool {
+	return r.Count(BlockFailed) == 0 && r.Count(BlockMismatch) == 0 && r.Count(BlockUnverified) == 0
+}
+
+// Count returns the number of blocks with the status.
+func (r *Report) Count(status BlockStatus) int {
+	var n int
+	for _, block := range r.Blocks {
+		if block.Status == status {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,249 @@
+// Package crypto1 implements the Crypto1 stream cipher used by MIFARE Classic cards,
+// as described in "Dismantling MIFARE Classic" by Garcia et al.
+//
+// The cipher is a 48-bit LFSR with a non-linear filter function. The state is kept
+// split into its odd and even bits, which makes the filter function cheap to compute.
+//
+// A reader authenticates with a card like this (all words are sent big-endian):
+//
+//	c := crypto1.NewCipher(key)
+//	c.Word(uid^nt, false)                    // nt is the card's nonce
+//	nrEnc := c.Word(nr, false) ^ nr          // nr is the reader's nonce
+//	arEnc := c.Word(0, false) ^ crypto1.Successor(nt, 64)
+//	at := c.Word(0, false) ^ atEnc           // must be crypto1.Successor(nt, 96)
+//
+// For example, the trace uid=9c599b32, nt=82a4166c, {nr}=a1e458ce, {ar}=6eea41e0, {at}=5cadf439
+// is a successful authentication with the key ffffffffffff.
+package crypto1
+
+// Feedback taps of the LFSR, split into odd and even bits.
+const (
+	polyOdd  = 0x29CE5C
+	polyEven = 0x870804
+)
+
+// Cipher is the state of the Crypto1 cipher.
+type Cipher struct {
+	// odd holds the odd bits of the LFSR.
+	odd uint32
+
+	// even holds the even bits of the LFSR.
+	even uint32
+}
+
+// NewCipher creates a cipher initialized with the 48-bit key (see Key).
+func NewCipher(key uint64) *Cipher {
+	c := &Cipher{}
+	for i := 47; i > 0; i -= 2 {
+		c.odd = c.odd<<1 | uint32(key>>((i-1)^7))&1
+		c.even = c.even<<1 | uint32(key>>(i^7))&1
+	}
+
+	return c
+}
+
+// Key converts a 6-byte key to the format used by NewCipher.
+func Key(key []byte) uint64 {
+	var k uint64
+	for _, b := range key[:6] {
+		k = k<<8 | uint64(b)
+	}
+
+	return k
+}
+
+// KeyBytes converts a key in the format used by NewCipher to 6 bytes.
+func KeyBytes(key uint64) []byte {
+	return []byte{byte(key >> 40), byte(key >> 32), byte(key >> 24), byte(key >> 16), byte(key >> 8), byte(key)}
+}
+
+// LFSR returns the state of the LFSR in the same format as the key. After rolling back
+// all bits that were shifted in since the initialization, this is the key.
+func (c *Cipher) LFSR() uint64 {
+	var lfsr uint64
+	for i := 23; i >= 0; i-- {
+		lfsr = lfsr<<1 | uint64(c.odd>>(i^3))&1
+		lfsr = lfsr<<1 | uint64(c.even>>(i^3))&1
+	}
+
+	return lfsr
+}
+
+// Filter returns the next keystream bit without shifting the LFSR.
+// It is used to encrypt the parity bit of a byte.
+func (c *Cipher) Filter() byte {
+	return filter(c.odd)
+}
+
+// Bit shifts the LFSR by one bit and returns the keystream bit.
+// The input bit is shifted into the LFSR. If encrypted is true, the input bit is
+// first decrypted with the keystream bit.
+func (c *Cipher) Bit(in byte, encrypted bool) byte {
+	ret := filter(c.odd)
+
+	feed := uint32(in & 1)
+	if encrypted {
+		feed ^= uint32(ret)
+	}
+	feed ^= polyOdd&c.odd ^ polyEven&c.even
+
+	c.even = c.even<<1 | parity32(feed)
+	c.odd, c.even = c.even, c.odd
+
+	return ret
+}
+
+// Byte shifts the LFSR by 8 bits (least significant bit first) and returns the keystream byte.
+func (c *Cipher) Byte(in byte, encrypted bool) byte {
+	var ret byte
+	for i := range 8 {
+		ret |= c.Bit(in>>i, encrypted) << i
+	}
+
+	return ret
+}
+
+// Word shifts the LFSR by 32 bits and returns the keystream word.
+// The bytes of the word are processed most significant byte first, which is the order they're sent in.
+func (c *Cipher) Word(in uint32, encrypted bool) uint32 {
+	var ret uint32
+	for i := 24; i >= 0; i -= 8 {
+		ret |= uint32(c.Byte(byte(in>>i), encrypted)) << i
+	}
+
+	return ret
+}
+
+// RollbackBit shifts the LFSR back by one bit, undoing Bit with the same arguments,
+// and returns the keystream bit.
+func (c *Cipher) RollbackBit(in byte, encrypted bool) byte {
+	c.odd &= 0xFFFFFF
+	c.odd, c.even = c.even, c.odd
+
+	out := c.even & 1
+	c.even >>= 1
+	out ^= polyEven&c.even ^ polyOdd&c.odd ^ uint32(in&1)
+
+	ret := filter(c.odd)
+	if encrypted {
+		out ^= uint32(ret)
+	}
+
+	c.even |= parity32(out) << 23
+
+	return ret
+}
+
+// RollbackByte shifts the LFSR back by 8 bits, undoing Byte with the same arguments.
+func (c *Cipher) RollbackByte(in byte, encrypted bool) byte {
+	var ret byte
+	for i := 7; i >= 0; i-- {
+		ret |= c.RollbackBit(in>>i, encrypted) << i
+	}
+
+	return ret
+}
+
+// RollbackWord shifts the LFSR back by 32 bits, undoing Word with the same arguments.
+func (c *Cipher) RollbackWord(in uint32, encrypted bool) uint32 {
+	var ret uint32
+	for i := 0; i <= 24; i += 8 {
+		ret |= uint32(c.RollbackByte(byte(in>>i), encrypted)) << i
+	}
+
+	return ret
+}
+
+// Encrypt encrypts the first bits of data in place and returns the encrypted parity
+// bits of the whole bytes. If feed is true, the plaintext is shifted into the LFSR,
+// which is how the reader nonce is sent during authentication.
+func (c *Cipher) Encrypt(data []byte, bits int, feed bool) []byte {
+	parity := make([]byte, 0, bits/8)
+	for i := 0; i < bits; i += 8 {
+		plain := data[i/8]
+		data[i/8] = plain ^ c.crypt(plain, min(bits-i, 8), feed, false)
+
+		if bits-i >= 8 {
+			parity = append(parity, Parity(plain)^c.Filter())
+		}
+	}
+
+	return parity
+}
+
+// Decrypt decrypts the first bits of data in place and reports whether the encrypted
+// parity bits of the whole bytes are correct. The parity is not checked if it is nil.
+// If feed is true, the plaintext is shifted into the LFSR.
+func (c *Cipher) Decrypt(data []byte, bits int, parity []byte, feed bool) bool {
+	ok := true
+	for i := 0; i < bits; i += 8 {
+		data[i/8] ^= c.crypt(data[i/8], min(bits-i, 8), feed, true)
+
+		if bits-i >= 8 && parity != nil && i/8 < len(parity) {
+			ok = ok && parity[i/8]&1 == Parity(data[i/8])^c.Filter()
+		}
+	}
+
+	return ok
+}
+
+// crypt shifts the LFSR by up to 8 bits and returns the keystream bits.
+func (c *Cipher) crypt(in byte, bits int, feed, encrypted bool) byte {
+	if !feed {
+		in, encrypted = 0, false
+	}
+
+	var ret byte
+	for i := range bits {
+		ret |= c.Bit(in>>i, encrypted) << i
+	}
+
+	return ret
+}
+
+// Successor returns the n-th successor of the nonce generated by the card's PRNG.
+// The reader answers with Successor(nt, 64), and the card with Successor(nt, 96).
+func Successor(x uint32, n int) uint32 {
+	x = swapEndian(x)
+	for range n {
+		x = x>>1 | (x>>16^x>>18^x>>19^x>>21)<<31
+	}
+
+	return swapEndian(x)
+}
+
+// Parity returns the odd parity bit of the byte, as sent after every byte on the RF interface.
+func Parity(b byte) byte {
+	b ^= b >> 4
+	b ^= b >> 2
+	b ^= b >> 1
+
+	return ^b & 1
+}
+
+// filter is the non-linear filter function, applied to 20 bits of the odd half of the LFSR.
+func filter(x uint32) byte {
+	f := uint32(0xf22c0) >> (x & 0xf) & 16
+	f |= uint32(0x6c9c0) >> (x >> 4 & 0xf) & 8
+	f |= uint32(0x3c8b0) >> (x >> 8 & 0xf) & 4
+	f |= uint32(0x1e458) >> (x >> 12 & 0xf) & 2
+	f |= uint32(0x0d938) >> (x >> 16 & 0xf) & 1
+
+	return byte(uint32(0xEC57E80A) >> f & 1)
+}
+
+// parity32 returns the even parity of the word.
+func parity32(x uint32) uint32 {
+	x ^= x >> 16
+	x ^= x >> 8
+	x ^= x >> 4
+	x ^= x >> 2
+	x ^= x >> 1
+
+	return x & 1
+}
+
+// swapEndian reverses the byte order of the word.
+func swapEndian(x uint32) uint32 {
+	return x>>24 | x>>8&0xFF00 | x<<8&0xFF0000 | x<<24
+}
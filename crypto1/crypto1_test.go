@@ -0,0 +1,168 @@
+package crypto1_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/msthtrifork/gorfid/crypto1"
+)
+
+// The authentication trace from the package documentation.
+const (
+	traceKey   = 0xFFFFFFFFFFFF
+	traceUID   = 0x9C599B32
+	traceNT    = 0x82A4166C
+	traceNREnc = 0xA1E458CE
+	traceAREnc = 0x6EEA41E0
+	traceATEnc = 0x5CADF439
+)
+
+func TestAuthenticationTrace(t *testing.T) {
+	c := crypto1.NewCipher(traceKey)
+	c.Word(traceUID^traceNT, false)
+	c.Word(traceNREnc, true)
+
+	if ar, want := c.Word(0, false)^traceAREnc, crypto1.Successor(traceNT, 64); ar != want {
+		t.Errorf("ar = %08x, want %08x", ar, want)
+	}
+	if at, want := c.Word(0, false)^traceATEnc, crypto1.Successor(traceNT, 96); at != want {
+		t.Errorf("at = %08x, want %08x", at, want)
+	}
+}
+
+func TestSuccessor(t *testing.T) {
+	tests := []struct {
+		n    int
+		want uint32
+	}{
+		{0, traceNT},
+		{64, 0x8D65734B},
+		{96, 0x9A427B20},
+	}
+
+	for _, tt := range tests {
+		if got := crypto1.Successor(traceNT, tt.n); got != tt.want {
+			t.Errorf("Successor(%08x, %d) = %08x, want %08x", traceNT, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRollbackRecoversKey(t *testing.T) {
+	c := crypto1.NewCipher(traceKey)
+	c.Word(traceUID^traceNT, false)
+	c.Word(traceNREnc, true)
+	c.Word(0, false)
+	c.Word(0, false)
+
+	c.RollbackWord(0, false)
+	c.RollbackWord(0, false)
+	c.RollbackWord(traceNREnc, true)
+	c.RollbackWord(traceUID^traceNT, false)
+
+	if key := c.LFSR(); key != traceKey {
+		t.Errorf("LFSR() after rollback = %012x, want %012x", key, uint64(traceKey))
+	}
+}
+
+func TestRollbackBit(t *testing.T) {
+	c := crypto1.NewCipher(0xA0A1A2A3A4A5)
+	ks := make([]byte, 0, 16)
+	for i := range 16 {
+		ks = append(ks, c.Bit(byte(i), i%3 == 0))
+	}
+
+	for i := 15; i >= 0; i-- {
+		if got := c.RollbackBit(byte(i), i%3 == 0); got != ks[i] {
+			t.Errorf("RollbackBit() of bit %d = %d, want %d", i, got, ks[i])
+		}
+	}
+	if key := c.LFSR(); key != 0xA0A1A2A3A4A5 {
+		t.Errorf("LFSR() after rollback = %012x, want a0a1a2a3a4a5", key)
+	}
+}
+
+func TestKey(t *testing.T) {
+	key := []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}
+
+	if got := crypto1.Key(key); got != 0xA0A1A2A3A4A5 {
+		t.Errorf("Key() = %012x, want a0a1a2a3a4a5", got)
+	}
+	if got := crypto1.KeyBytes(0xA0A1A2A3A4A5); !bytes.Equal(got, key) {
+		t.Errorf("KeyBytes() = % x, want % x", got, key)
+	}
+	if got := crypto1.NewCipher(0xA0A1A2A3A4A5).LFSR(); got != 0xA0A1A2A3A4A5 {
+		t.Errorf("NewCipher().LFSR() = %012x, want a0a1a2a3a4a5", got)
+	}
+}
+
+func TestParity(t *testing.T) {
+	tests := []struct {
+		b    byte
+		want byte
+	}{
+		{0x00, 1},
+		{0x01, 0},
+		{0x03, 1},
+		{0x93, 1},
+		{0xFF, 1},
+		{0xFE, 0},
+	}
+
+	for _, tt := range tests {
+		if got := crypto1.Parity(tt.b); got != tt.want {
+			t.Errorf("Parity(%02x) = %d, want %d", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		bits int
+		feed bool
+	}{
+		{"byte", []byte{0x30}, 8, false},
+		{"ACK", []byte{0x0A}, 4, false},
+		{"block", []byte("0123456789abcdef"), 128, false},
+		{"partial byte", []byte{0x01, 0x02, 0x03, 0x04, 0x0A}, 36, false},
+		{"reader nonce", []byte{0x12, 0x34, 0x56, 0x78}, 32, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, dec := crypto1.NewCipher(0x123456789ABC), crypto1.NewCipher(0x123456789ABC)
+
+			data := bytes.Clone(tt.data)
+			parity := enc.Encrypt(data, tt.bits, tt.feed)
+			if len(parity) != tt.bits/8 {
+				t.Fatalf("Encrypt() returned %d parity bits, want %d", len(parity), tt.bits/8)
+			}
+			if tt.bits >= 16 && bytes.Equal(data, tt.data) {
+				t.Errorf("Encrypt() didn't change the data")
+			}
+
+			if !dec.Decrypt(data, tt.bits, parity, tt.feed) {
+				t.Errorf("Decrypt() reported a wrong parity")
+			}
+			if !bytes.Equal(data, tt.data) {
+				t.Errorf("Decrypt() = % x, want % x", data, tt.data)
+			}
+			if enc.LFSR() != dec.LFSR() {
+				t.Errorf("LFSR() differs after decrypting: %012x, want %012x", dec.LFSR(), enc.LFSR())
+			}
+		})
+	}
+}
+
+func TestDecryptWrongParity(t *testing.T) {
+	enc, dec := crypto1.NewCipher(0x123456789ABC), crypto1.NewCipher(0x123456789ABC)
+
+	data := []byte{0x30, 0x04}
+	parity := enc.Encrypt(data, 16, false)
+	parity[1] ^= 1
+
+	if dec.Decrypt(data, 16, parity, false) {
+		t.Errorf("Decrypt() accepted a flipped parity bit")
+	}
+}
@@ -1,10 +1,16 @@
+//go:build tinygo
+
 package main
 
 import (
+	"context"
+	"errors"
 	"machine"
+	"sync"
 	"time"
 
-	"gorfid/mfrc522"
+	"github.com/msthtrifork/gorfid/clone"
+	"github.com/msthtrifork/gorfid/mfrc522"
 )
 
 type state = int
@@ -34,64 +40,147 @@ func main() {
 	ledBlue.Configure(machine.PinConfig{Mode: machine.PinOutput})
 	button.Configure(machine.PinConfig{Mode: machine.PinInput})
 
-	// Set up button to switch states
-	var state state
+	// Set up button to switch states. The interrupt handler only records the press, and the
+	// goroutine switches the state and aborts the pending operation.
+	presses := make(chan struct{}, 1)
 	if err = button.SetInterrupt(machine.PinRising, func(machine.Pin) {
-		switch state {
-		case stateRead:
-			state = stateClone
-		case stateClone:
-			state = stateWrite
-		case stateWrite:
-			state = stateRead
+		select {
+		case presses <- struct{}{}:
+		default:
 		}
-		println("State:", state)
 	}); err != nil {
 		println("Failed to set button interrupt", err)
 		return
 	}
 
+	// mu guards state and cancel, which are shared with the main loop
+	var mu sync.Mutex
+	var state state
+	cancel := context.CancelFunc(func() {})
+	go func() {
+		for range presses {
+			mu.Lock()
+			switch state {
+			case stateRead:
+				state = stateClone
+			case stateClone:
+				state = stateWrite
+			case stateWrite:
+				state = stateRead
+			}
+			println("State:", state)
+			cancel()
+			mu.Unlock()
+		}
+	}()
+
+	keys := mfrc522.KeyMap{Common: clone.DefaultKeys}
+
 	// Main loop
-	var tagData []byte
+	var image *clone.Image
 	for {
-		switch state {
+		ctx, cancelCtx := context.WithCancel(context.Background())
+		mu.Lock()
+		cancel()
+		cancel = cancelCtx
+		current := state
+		mu.Unlock()
+
+		switch current {
 		case stateRead:
 			// Green
 			ledRed.High()
 			ledGreen.Low()
 			ledBlue.High()
 
-			uid, err := rfid.ReadTagUUID()
-			if err != nil {
-				continue
+			// Report cards until the button switches the state
+			for event := range rfid.Watch(ctx, mfrc522.WatchOptions{}) {
+				switch event := event.(type) {
+				case mfrc522.CardArrived:
+					println("Tag detected:", event.UID, event.Card().Family().String())
+				case mfrc522.CardRemoved:
+					println("Tag removed:", event.UID)
+				}
 			}
-
-			println("Tag detected:", uid)
 		case stateClone:
 			// Blue
 			ledRed.High()
 			ledGreen.High()
 			ledBlue.Low()
 
-			uid, err := rfid.ReadTagUUID()
+			img, err := clone.CaptureContext(ctx, rfid, keys)
+			if img == nil && errors.Is(err, mfrc522.ErrNoCard) || errors.Is(err, context.Canceled) {
+				continue
+			}
 			if err != nil {
+				println("Failed to clone tag:", err.Error())
 				continue
 			}
-			tagData = uid
+			image = img
 
-			println("Tag cloned:", uid)
+			println("Tag cloned:", image.Card.UUID, "complete:", image.Complete())
+			waitForRemoval(ctx, rfid)
 		case stateWrite:
 			// Red
 			ledRed.Low()
 			ledGreen.High()
 			ledBlue.High()
 
-			if tagData == nil {
+			if image == nil {
 				println("No tag data to write")
-				time.Sleep(1 * time.Second)
+				select {
+				case <-ctx.Done():
+				case <-time.After(1 * time.Second):
+				}
+				continue
 			}
 
-			println("not implemented")
+			report, err := clone.WriteContext(ctx, rfid, image, clone.Options{Keys: keys, Block0: true})
+			if errors.Is(err, mfrc522.ErrNoCard) || errors.Is(err, context.Canceled) {
+				continue
+			}
+			if err != nil {
+				println("Failed to write tag:", err.Error())
+				continue
+			}
+
+			for addr, block := range report.Blocks {
+				if block.Status != clone.BlockVerified {
+					println("Block", addr, block.Status.String())
+				}
+			}
+			println("Tag written:", report.Count(clone.BlockVerified), "blocks verified, OK:", report.OK())
+			waitForRemoval(ctx, rfid)
+		}
+	}
+}
+
+// waitForRemoval waits until the card on the reader left the RF field, so a card that is held
+// on the reader is only cloned or written once. It returns right away if there is no card,
+// and early when ctx is canceled.
+func waitForRemoval(ctx context.Context, rfid *mfrc522.MFRC522) {
+	ctx, cancel := context.WithCancel(ctx)
+	events := rfid.Watch(ctx, mfrc522.WatchOptions{})
+	defer func() {
+		// The reader can't be used until the channel is closed
+		cancel()
+		for range events {
+		}
+	}()
+
+	// Watch checks for a card right away, so it is gone if none arrived in time
+	select {
+	case event := <-events:
+		if _, ok := event.(mfrc522.CardArrived); !ok {
+			return
+		}
+	case <-time.After(time.Second):
+		return
+	}
+
+	for event := range events {
+		if _, ok := event.(mfrc522.CardRemoved); ok {
+			return
 		}
 	}
 }
@@ -0,0 +1,154 @@
+package mfrc522
+
+// Bus is the host interface used to access the MFRC522 registers.
+// The reader can be connected over SPI, I2C or UART, and each of them encodes
+// register addresses differently (Chapter 8.1 of the MFRC55 datasheet),
+// so the encoding is left to the implementation.
+type Bus interface {
+	// ReadRegisterBytes reads readLen bytes from the specified register.
+	ReadRegisterBytes(reg Register, readLen int) ([]byte, error)
+
+	// WriteRegisterBytes writes the bytes to the specified register.
+	WriteRegisterBytes(reg Register, val []byte) error
+}
+
+// Pin is a GPIO line connected to the MFRC522 reader, such as the reset or interrupt pin.
+type Pin interface {
+	// Get configures the pin as an input and returns its level.
+	Get() bool
+
+	// Set configures the pin as an output and drives it to the given level.
+	Set(high bool)
+
+	// SetInterrupt calls callback whenever the level of the pin changes.
+	// The callback can be called from an interrupt handler, so it must not block.
+	// A nil callback disables the interrupt.
+	SetInterrupt(callback func()) error
+}
+
+// SPI is the part of the host's SPI interface that is needed by SPIBus.
+// It is implemented by machine.SPI in TinyGo.
+type SPI interface {
+	Tx(w, r []byte) error
+}
+
+// SPIBus accesses the MFRC522 registers over SPI (Chapter 8.1.2 of the MFRC55 datasheet).
+type SPIBus struct {
+	// spi is the host's SPI interface.
+	spi SPI
+
+	// cs is the chip-select (SDA) pin of the reader.
+	// It can be nil if the SPI interface handles chip-select on its own.
+	cs Pin
+}
+
+// NewSPIBus creates a new SPI bus. The cs pin is held low for the duration of
+// every transfer and can be nil if the SPI interface handles chip-select.
+func NewSPIBus(spi SPI, cs Pin) *SPIBus {
+	if cs != nil {
+		cs.Set(true)
+	}
+
+	return &SPIBus{spi: spi, cs: cs}
+}
+
+// ReadRegisterBytes reads readLen bytes from the specified register.
+// The register address is repeated for every byte, as described in Chapter 8.1.2.1.
+func (b *SPIBus) ReadRegisterBytes(reg Register, readLen int) ([]byte, error) {
+	if readLen < 1 {
+		return nil, nil
+	}
+
+	data := make([]byte, 0, readLen+1)
+	for range readLen {
+		data = append(data, 0x80|(reg<<1)&0x7E)
+	}
+	data = append(data, 0)
+
+	res := make([]byte, len(data))
+	if err := b.tx(data, res); err != nil {
+		return nil, err
+	}
+
+	return res[1:], nil
+}
+
+// WriteRegisterBytes writes the bytes to the specified register.
+func (b *SPIBus) WriteRegisterBytes(reg Register, val []byte) error {
+	data := append([]byte{(reg << 1) & 0x7E}, val...)
+
+	return b.tx(data, nil)
+}
+
+// tx runs a single SPI transfer with the chip-select pin held low.
+func (b *SPIBus) tx(w, r []byte) error {
+	if b.cs != nil {
+		b.cs.Set(false)
+		defer b.cs.Set(true)
+	}
+
+	return b.spi.Tx(w, r)
+}
+
+// I2C is the part of the host's I2C interface that is needed by I2CBus.
+// It is implemented by machine.I2C in TinyGo.
+type I2C interface {
+	Tx(addr uint16, w, r []byte) error
+}
+
+// DefaultI2CAddress is the I2C address of a reader with the pins EA, ADR_0, ADR_1 and ADR_2 tied low.
+const DefaultI2CAddress = 0x28
+
+// I2CAddress returns the 7-bit I2C address of a reader, which is set by its pins
+// (Chapter 8.1.4 of the MFRC55 datasheet). If pin EA is low, the upper four bits
+// are fixed to 0101b and only ADR_0 to ADR_2 are used. If pin EA is high,
+// ADR_0 to ADR_5 set the lower six bits, and the upper bit is 0.
+func I2CAddress(ea bool, adr byte) uint16 {
+	if ea {
+		return uint16(adr & 0x3F)
+	}
+
+	return DefaultI2CAddress | uint16(adr&0x07)
+}
+
+// I2CBus accesses the MFRC522 registers over I2C (Chapter 8.1.4 of the MFRC55 datasheet).
+type I2CBus struct {
+	// i2c is the host's I2C interface.
+	i2c I2C
+
+	// addr is the 7-bit I2C address of the reader.
+	addr uint16
+}
+
+// NewI2CBus creates a new I2C bus for the reader with the 7-bit address (see I2CAddress).
+func NewI2CBus(i2c I2C, addr uint16) *I2CBus {
+	return &I2CBus{i2c: i2c, addr: addr & 0x7F}
+}
+
+// ReadRegisterBytes reads readLen bytes from the specified register.
+// The register address is sent in a write transfer first, and the reader then returns the
+// register's content for every byte of the read transfer without incrementing the address,
+// so the whole FIFO buffer can be read at once.
+func (b *I2CBus) ReadRegisterBytes(reg Register, readLen int) ([]byte, error) {
+	if readLen < 1 {
+		return nil, nil
+	}
+
+	if err := b.i2c.Tx(b.addr, []byte{reg & 0x3F}, nil); err != nil {
+		return nil, err
+	}
+
+	res := make([]byte, readLen)
+	if err := b.i2c.Tx(b.addr, nil, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// WriteRegisterBytes writes the bytes to the specified register.
+func (b *I2CBus) WriteRegisterBytes(reg Register, val []byte) error {
+	data := append([]byte{reg & 0x3F}, val...)
+
+	return b.i2c.Tx(b.addr, data, nil)
+}
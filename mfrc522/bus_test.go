@@ -0,0 +1,230 @@
+package mfrc522_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+	"github.com/msthtrifork/gorfid/mfrc522/sim"
+)
+
+// i2cTransfer is a transfer on the fake I2C interface.
+type i2cTransfer struct {
+	addr    uint16
+	w       []byte
+	readLen int
+}
+
+// fakeI2C is the host's I2C interface, connected to a simulated chip like the reader's I2C interface:
+// a write transfer sets the register and writes the following bytes to it, and a read transfer
+// reads the register for every byte.
+type fakeI2C struct {
+	chip *sim.Chip
+
+	// reg is the register set by the last write transfer.
+	reg mfrc522.Register
+
+	// transfers are the transfers in the order they were made.
+	transfers []i2cTransfer
+
+	// err is returned by the transfers, if set.
+	err error
+}
+
+// Tx records the transfer and passes it on to the simulated chip.
+func (f *fakeI2C) Tx(addr uint16, w, r []byte) error {
+	f.transfers = append(f.transfers, i2cTransfer{addr: addr, w: append([]byte(nil), w...), readLen: len(r)})
+	if f.err != nil {
+		return f.err
+	}
+
+	if len(w) > 0 {
+		f.reg = mfrc522.Register(w[0])
+		if len(w) > 1 {
+			if err := f.chip.WriteRegisterBytes(f.reg, w[1:]); err != nil {
+				return err
+			}
+		}
+	}
+	if len(r) > 0 {
+		val, err := f.chip.ReadRegisterBytes(f.reg, len(r))
+		if err != nil {
+			return err
+		}
+		copy(r, val)
+	}
+
+	return nil
+}
+
+// newI2CBus creates an I2C bus with the address for a fake I2C interface.
+func newI2CBus(addr uint16) (*mfrc522.I2CBus, *fakeI2C, *sim.Chip) {
+	c := sim.NewChip()
+	i2c := &fakeI2C{chip: c}
+
+	return mfrc522.NewI2CBus(i2c, addr), i2c, c
+}
+
+// checkTransfers checks that the transfers were made.
+func checkTransfers(t *testing.T, got, want []i2cTransfer) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%d transfers, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].addr != want[i].addr || !bytes.Equal(got[i].w, want[i].w) || got[i].readLen != want[i].readLen {
+			t.Errorf("transfer %d = %02x, % x, read %d, want %02x, % x, read %d", i,
+				got[i].addr, got[i].w, got[i].readLen, want[i].addr, want[i].w, want[i].readLen)
+		}
+	}
+}
+
+func TestI2CAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		ea   bool
+		adr  byte
+		want uint16
+	}{
+		{"EA low", false, 0x00, 0x28},
+		{"EA low with ADR_0", false, 0x01, 0x29},
+		{"EA low with ADR_0 to ADR_2", false, 0x07, 0x2F},
+		{"EA low ignores ADR_3 to ADR_5", false, 0x3A, 0x2A},
+		{"EA high", true, 0x00, 0x00},
+		{"EA high with ADR_0 to ADR_5", true, 0x3F, 0x3F},
+		{"EA high with some pins", true, 0x2A, 0x2A},
+		{"EA high ignores the upper bits", true, 0xC5, 0x05},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mfrc522.I2CAddress(tt.ea, tt.adr); got != tt.want {
+				t.Errorf("I2CAddress(%t, %02x) = %02x, want %02x", tt.ea, tt.adr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestI2CBusRegisters(t *testing.T) {
+	// The address is masked to 7 bits
+	bus, i2c, _ := newI2CBus(0x80 | mfrc522.I2CAddress(false, 0x03))
+
+	if err := bus.WriteRegisterBytes(mfrc522.ModWidthReg, []byte{0x30}); err != nil {
+		t.Fatalf("WriteRegisterBytes() error = %v", err)
+	}
+	val, err := bus.ReadRegisterBytes(mfrc522.ModWidthReg, 1)
+	if err != nil {
+		t.Fatalf("ReadRegisterBytes() error = %v", err)
+	}
+	if !bytes.Equal(val, []byte{0x30}) {
+		t.Errorf("ReadRegisterBytes() = % x, want 30", val)
+	}
+
+	want := []i2cTransfer{
+		{addr: 0x2B, w: []byte{byte(mfrc522.ModWidthReg), 0x30}},
+		{addr: 0x2B, w: []byte{byte(mfrc522.ModWidthReg)}},
+		{addr: 0x2B, w: []byte{}, readLen: 1},
+	}
+	checkTransfers(t, i2c.transfers, want)
+}
+
+func TestI2CBusRegisterAddress(t *testing.T) {
+	bus, i2c, _ := newI2CBus(mfrc522.DefaultI2CAddress)
+
+	// Only the lower six bits are sent as the register address
+	val, err := bus.ReadRegisterBytes(0xC0|mfrc522.VersionReg, 1)
+	if err != nil {
+		t.Fatalf("ReadRegisterBytes() error = %v", err)
+	}
+	if !bytes.Equal(val, []byte{0x92}) {
+		t.Errorf("ReadRegisterBytes() = % x, want 92", val)
+	}
+	if err := bus.WriteRegisterBytes(0x40|mfrc522.ModWidthReg, []byte{0x30}); err != nil {
+		t.Fatalf("WriteRegisterBytes() error = %v", err)
+	}
+
+	want := []i2cTransfer{
+		{addr: mfrc522.DefaultI2CAddress, w: []byte{byte(mfrc522.VersionReg)}},
+		{addr: mfrc522.DefaultI2CAddress, w: []byte{}, readLen: 1},
+		{addr: mfrc522.DefaultI2CAddress, w: []byte{byte(mfrc522.ModWidthReg), 0x30}},
+	}
+	checkTransfers(t, i2c.transfers, want)
+}
+
+func TestI2CBusFIFO(t *testing.T) {
+	bus, i2c, _ := newI2CBus(mfrc522.DefaultI2CAddress)
+
+	data := make([]byte, 64)
+	for i := range data {
+		data[i] = byte(i * 3)
+	}
+	if err := bus.WriteRegisterBytes(mfrc522.FIFODataReg, data); err != nil {
+		t.Fatalf("WriteRegisterBytes() error = %v", err)
+	}
+
+	// The reader doesn't increment the address, so the whole FIFO buffer is read in one transfer
+	got, err := bus.ReadRegisterBytes(mfrc522.FIFODataReg, len(data))
+	if err != nil {
+		t.Fatalf("ReadRegisterBytes() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("ReadRegisterBytes() = % x, want % x", got, data)
+	}
+
+	want := []i2cTransfer{
+		{addr: mfrc522.DefaultI2CAddress, w: append([]byte{byte(mfrc522.FIFODataReg)}, data...)},
+		{addr: mfrc522.DefaultI2CAddress, w: []byte{byte(mfrc522.FIFODataReg)}},
+		{addr: mfrc522.DefaultI2CAddress, w: []byte{}, readLen: 64},
+	}
+	checkTransfers(t, i2c.transfers, want)
+}
+
+func TestI2CBusError(t *testing.T) {
+	bus, i2c, _ := newI2CBus(mfrc522.DefaultI2CAddress)
+	errBus := errors.New("bus error")
+	i2c.err = errBus
+
+	if _, err := bus.ReadRegisterBytes(mfrc522.VersionReg, 1); !errors.Is(err, errBus) {
+		t.Errorf("ReadRegisterBytes() error = %v, want %v", err, errBus)
+	}
+	if err := bus.WriteRegisterBytes(mfrc522.ModWidthReg, []byte{0x30}); !errors.Is(err, errBus) {
+		t.Errorf("WriteRegisterBytes() error = %v, want %v", err, errBus)
+	}
+}
+
+func TestI2CBusReader(t *testing.T) {
+	bus, i2c, c := newI2CBus(mfrc522.DefaultI2CAddress)
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	c.Add(card)
+	m, err := mfrc522.New(bus, c.RST(), c.IRQ(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	info, err := m.ReadCard()
+	if err != nil {
+		t.Fatalf("ReadCard() error = %v", err)
+	}
+	if !bytes.Equal(info.UUID, card.UID()) {
+		t.Errorf("ReadCard().UUID = % x, want % x", info.UUID, card.UID())
+	}
+
+	// The self-test reads its 64 bytes from the FIFO buffer
+	result, err := m.SelfTest()
+	if err != nil {
+		t.Fatalf("SelfTest() error = %v", err)
+	}
+	if !result.Passed() {
+		t.Errorf("SelfTest() = %d diffs, want it to pass", len(result.Diffs))
+	}
+
+	for _, transfer := range i2c.transfers {
+		if transfer.addr != mfrc522.DefaultI2CAddress || len(transfer.w) > 0 && transfer.w[0] > 0x3F {
+			t.Fatalf("transfer to %02x with % x, want the address %02x and a register",
+				transfer.addr, transfer.w, mfrc522.DefaultI2CAddress)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package mfrc522
+
+// CardInfo identifies a card in the reader's RF field.
+type CardInfo struct {
+	// UUID is the complete UUID of the card (4, 7 or 10 bytes).
+	UUID []byte
+
+	// ATQA is the answer to the request (REQA or WUPA) that woke up the card,
+	// with the first byte received in the low byte.
+	ATQA uint16
+
+	// SAK is the select acknowledge of the last cascade level.
+	SAK byte
+}
+
+// CardFamily is the family of a card, as identified by its ATQA and SAK.
+type CardFamily byte
+
+// Card families (NXP AN10833, MIFARE type identification procedure)
+const (
+	FamilyUnknown CardFamily = iota
+	FamilyClassicMini
+	FamilyClassic1K
+	FamilyClassic4K
+	FamilyUltralight
+	FamilyPlus
+	FamilyDESFire
+	FamilyISODEP
+)
+
+// String returns the name of the card family.
+func (f CardFamily) String() string {
+	switch f {
+	case FamilyClassicMini:
+		return "MIFARE Classic Mini"
+	case FamilyClassic1K:
+		return "MIFARE Classic 1K"
+	case FamilyClassic4K:
+		return "MIFARE Classic 4K"
+	case FamilyUltralight:
+		return "MIFARE Ultralight/NTAG"
+	case FamilyPlus:
+		return "MIFARE Plus"
+	case FamilyDESFire:
+		return "MIFARE DESFire"
+	case FamilyISODEP:
+		return "ISO 14443-4"
+	default:
+		return "unknown"
+	}
+}
+
+// Classic reports whether the family is one of the MIFARE Classic cards.
+func (f CardFamily) Classic() bool {
+	return f == FamilyClassicMini || f == FamilyClassic1K || f == FamilyClassic4K
+}
+
+// Sectors returns the number of sectors of a MIFARE Classic card family, or 0 for other families.
+func (f CardFamily) Sectors() int {
+	switch f {
+	case FamilyClassicMini:
+		return 5
+	case FamilyClassic1K:
+		return 16
+	case FamilyClassic4K:
+		return 40
+	default:
+		return 0
+	}
+}
+
+// UUIDLength returns the length of the card's UUID in bytes.
+func (c CardInfo) UUIDLength() int {
+	return len(c.UUID)
+}
+
+// ISODEP reports whether the card supports ISO 14443-4 (ISO-DEP) and can be sent APDUs.
+// Some cards, like MIFARE Plus in security level 1, support it next to the MIFARE Classic protocol.
+func (c CardInfo) ISODEP() bool {
+	return c.SAK&0x20 != 0
+}
+
+// Family decodes the card family from the ATQA and SAK.
+func (c CardInfo) Family() CardFamily {
+	switch c.SAK {
+	case 0x09:
+		return FamilyClassicMini
+	case 0x08, 0x28, 0x88:
+		return FamilyClassic1K
+	case 0x18, 0x38:
+		return FamilyClassic4K
+	case 0x00:
+		return FamilyUltralight
+	case 0x10, 0x11:
+		return FamilyPlus
+	case 0x20:
+		// Only the ATQA tells the ISO 14443-4 cards apart
+		switch c.ATQA {
+		case 0x0344:
+			return FamilyDESFire
+		case 0x0002, 0x0004, 0x0042, 0x0044:
+			return FamilyPlus
+		}
+
+		return FamilyISODEP
+	}
+
+	if c.ISODEP() {
+		return FamilyISODEP
+	}
+
+	return FamilyUnknown
+}
@@ -0,0 +1,247 @@
+package mfrc522
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// KeyMap holds the keys that are tried to authenticate the sectors of a MIFARE Classic card.
+// Every key is tried as key A and as key B.
+type KeyMap struct {
+	// Sectors holds keys for specific sectors, which are tried before the common keys.
+	Sectors map[byte][][]byte
+
+	// Common holds keys that are tried for every sector.
+	Common [][]byte
+}
+
+// Keys returns the keys to try for the sector.
+func (k KeyMap) Keys(sector byte) [][]byte {
+	keys := k.Sectors[sector]
+
+	// Don't append to the caller's slice
+	return append(keys[:len(keys):len(keys)], k.Common...)
+}
+
+// SectorKeys are the keys that authenticated a sector. A key is nil if none of the tried keys worked.
+type SectorKeys struct {
+	KeyA []byte
+	KeyB []byte
+}
+
+// ClassicDump is the content of a MIFARE Classic card.
+type ClassicDump struct {
+	// Card is the identification of the dumped card.
+	Card CardInfo
+
+	// Blocks holds the data of every block on the card, or nil if the block couldn't be read.
+	// The keys in the sector trailers are filled in if they are known, since the card
+	// never returns key A (and only returns key B if it can't be used for authentication).
+	Blocks [][]byte
+
+	// Keys holds the keys that authenticated each sector.
+	Keys []SectorKeys
+}
+
+// Complete reports whether all blocks of the card were read.
+func (d *ClassicDump) Complete() bool {
+	for _, block := range d.Blocks {
+		if block == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Bytes returns the dump as a binary image, in the format used by most MIFARE tools.
+// Blocks that couldn't be read are filled with zeros.
+func (d *ClassicDump) Bytes() []byte {
+	data := make([]byte, 0, len(d.Blocks)*16)
+	for _, block := range d.Blocks {
+		if block == nil {
+			block = make([]byte, 16)
+		}
+		data = append(data, block...)
+	}
+
+	return data
+}
+
+// DumpClassic selects a MIFARE Classic card and reads all of its blocks (see Session.DumpClassic).
+func (m *MFRC522) DumpClassic(keys KeyMap) (*ClassicDump, error) {
+	return m.DumpClassicContext(context.Background(), keys)
+}
+
+// DumpClassicContext is like DumpClassic, but stops when the context is canceled or its deadline expires.
+func (m *MFRC522) DumpClassicContext(ctx context.Context, keys KeyMap) (*ClassicDump, error) {
+	s, err := m.SelectContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = s.Close() }()
+
+	return s.DumpClassicContext(ctx, keys)
+}
+
+// DumpClassic reads all blocks of the selected MIFARE Classic card, authenticating each sector
+// with the keys from the key map. Key A is tried first, and key B is used for the blocks that
+// couldn't be read with key A. Blocks that can't be read with any key are marked in the dump
+// instead of failing the whole dump.
+//
+// An error is only returned if the card isn't a MIFARE Classic card or if the card is lost,
+// in which case the returned dump contains the blocks that were read until then.
+func (s *Session) DumpClassic(keys KeyMap) (*ClassicDump, error) {
+	return s.DumpClassicContext(context.Background(), keys)
+}
+
+// DumpClassicContext is like DumpClassic, but stops when the context is canceled or its deadline expires.
+// The blocks read until then are returned with the error.
+func (s *Session) DumpClassicContext(ctx context.Context, keys KeyMap) (*ClassicDump, error) {
+	family := s.card.Family()
+	if !family.Classic() {
+		return nil, errors.New("not a MIFARE Classic card: " + family.String())
+	}
+
+	sectors := byte(family.Sectors())
+	dump := &ClassicDump{
+		Card:   s.card,
+		Blocks: make([][]byte, SectorFirstBlock(sectors)),
+		Keys:   make([]SectorKeys, sectors),
+	}
+
+	for sector := range sectors {
+		if err := s.dumpSector(ctx, dump, sector, keys.Keys(sector)); err != nil {
+			return dump, err
+		}
+	}
+
+	return dump, nil
+}
+
+// dumpSector reads the blocks of the sector into the dump.
+func (s *Session) dumpSector(ctx context.Context, dump *ClassicDump, sector byte, keys [][]byte) error {
+	first := SectorFirstBlock(sector)
+	last := first + int(SectorBlocks(sector)) - 1
+	trailer := byte(last)
+	found := &dump.Keys[sector]
+
+	for _, authMode := range []byte{AuthKeyACmd, AuthKeyBCmd} {
+		if sectorRead(dump, first, last) {
+			break
+		}
+
+		for _, key := range keys {
+			if err := s.AuthenticateContext(ctx, authMode, trailer, key); err != nil {
+				if s.closed {
+					return wrap("lost the card while dumping", err)
+				}
+
+				continue
+			}
+
+			if authMode == AuthKeyACmd {
+				found.KeyA = key
+			} else {
+				found.KeyB = key
+			}
+
+			for addr := first; addr <= last; addr++ {
+				if dump.Blocks[addr] != nil {
+					continue
+				}
+
+				// A refused read ends the authentication
+				if err := s.AuthenticateContext(ctx, authMode, trailer, key); err != nil {
+					break
+				}
+
+				data, err := s.ReadBlockContext(ctx, byte(addr))
+				if err != nil {
+					if s.closed {
+						return wrap("lost the card while dumping", err)
+					}
+
+					continue
+				}
+				dump.Blocks[addr] = data
+			}
+
+			break
+		}
+	}
+
+	// Fill in the keys the card doesn't return
+	if block := dump.Blocks[last]; block != nil {
+		if found.KeyA != nil {
+			copy(block[:6], found.KeyA)
+		}
+		if found.KeyB != nil {
+			copy(block[10:], found.KeyB)
+		}
+	}
+
+	return nil
+}
+
+// sectorRead reports whether the blocks from first to last were read.
+func sectorRead(dump *ClassicDump, first, last int) bool {
+	for _, block := range dump.Blocks[first : last+1] {
+		if block == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SectorBlocks returns the number of blocks in the sector of a MIFARE Classic card.
+// The first 32 sectors have 4 blocks, and the remaining sectors of a 4K card have 16 blocks.
+// The last block of a sector is its trailer.
+func SectorBlocks(sector byte) byte {
+	if sector < 32 {
+		return 4
+	}
+
+	return 16
+}
+
+// SectorFirstBlock returns the address of the first block in the sector.
+// For the number of sectors on a card, this is the number of blocks.
+func SectorFirstBlock(sector byte) int {
+	if sector < 32 {
+		return int(sector) * 4
+	}
+
+	return 128 + int(sector-32)*16
+}
+
+// BlockSector returns the sector of the block address on a MIFARE Classic card.
+func BlockSector(addr byte) byte {
+	if addr < 128 {
+		return addr / 4
+	}
+
+	return 32 + (addr-128)/16
+}
+
+// isTrailer reports whether the block address is the trailer of its sector.
+func isTrailer(addr byte) bool {
+	sector := BlockSector(addr)
+
+	return int(addr) == SectorFirstBlock(sector)+int(SectorBlocks(sector))-1
+}
+
+// blockAddr returns the address of the block in the sector.
+func blockAddr(sector, block byte) (byte, error) {
+	if sector >= 40 {
+		return 0, errors.New("invalid sector, expected 0-39")
+	}
+	if block >= SectorBlocks(sector) {
+		return 0, errors.New("invalid block, sector " + strconv.Itoa(int(sector)) + " has " +
+			strconv.Itoa(int(SectorBlocks(sector))) + " blocks")
+	}
+
+	return byte(SectorFirstBlock(sector) + int(block)), nil
+}
@@ -0,0 +1,109 @@
+package mfrc522_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+	"github.com/msthtrifork/gorfid/mfrc522/sim"
+)
+
+func TestDumpClassic(t *testing.T) {
+	keyA := []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}
+	keyB := []byte{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5}
+	unknown := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	for block := 1; block < 64; block++ {
+		if block%4 != 3 {
+			card.SetBlock(block, [16]byte{byte(block), 0xAA})
+		}
+	}
+	card.SetTrailer(1, keyA, sim.TransportAccessBits, sim.DefaultKey)
+	card.SetTrailer(3, unknown, sim.TransportAccessBits, unknown)
+	card.SetTrailer(5, unknown, secretKeyB, keyB)
+	m, _ := newReader(t, card)
+
+	dump, err := m.DumpClassic(mfrc522.KeyMap{
+		Sectors: map[byte][][]byte{5: {keyB}},
+		Common:  [][]byte{sim.DefaultKey, keyA},
+	})
+	if err != nil {
+		t.Fatalf("DumpClassic() error = %v", err)
+	}
+
+	if !bytes.Equal(dump.Card.UUID, card.UID()) {
+		t.Errorf("DumpClassic().Card.UUID = % x, want % x", dump.Card.UUID, card.UID())
+	}
+	if len(dump.Blocks) != 64 || len(dump.Keys) != 16 {
+		t.Fatalf("DumpClassic() = %d blocks and %d sectors, want 64 and 16", len(dump.Blocks), len(dump.Keys))
+	}
+	if dump.Complete() {
+		t.Error("Complete() = true, want false because of sector 3")
+	}
+
+	for addr, block := range dump.Blocks {
+		want := card.Block(addr)
+		switch {
+		case addr/4 == 3:
+			if block != nil {
+				t.Errorf("block %d = % x, want nil", addr, block)
+			}
+		case addr == 23:
+			// Key A of sector 5 is unknown, so it is returned as zeros
+			if !bytes.Equal(block[:6], make([]byte, 6)) || !bytes.Equal(block[6:], want[6:]) {
+				t.Errorf("block %d = % x, want % x without key A", addr, block, want)
+			}
+		case !bytes.Equal(block, want[:]):
+			t.Errorf("block %d = % x, want % x", addr, block, want)
+		}
+	}
+
+	tests := []struct {
+		sector     byte
+		keyA, keyB []byte
+	}{
+		{0, sim.DefaultKey, nil},
+		{1, keyA, nil},
+		{3, nil, nil},
+		{5, nil, keyB},
+	}
+	for _, tt := range tests {
+		keys := dump.Keys[tt.sector]
+		if !bytes.Equal(keys.KeyA, tt.keyA) || !bytes.Equal(keys.KeyB, tt.keyB) {
+			t.Errorf("Keys[%d] = % x / % x, want % x / % x", tt.sector, keys.KeyA, keys.KeyB, tt.keyA, tt.keyB)
+		}
+	}
+}
+
+func TestDumpClassicBytes(t *testing.T) {
+	tests := []struct {
+		name   string
+		typ    sim.ClassicType
+		blocks int
+	}{
+		{"mini", sim.ClassicMini, 20},
+		{"1K", sim.Classic1K, 64},
+		{"4K", sim.Classic4K, 256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := newCard(t, tt.typ, 0xDE, 0xAD, 0xBE, 0xEF)
+			card.SetBlock(tt.blocks-2, [16]byte{0x01, 0x02, 0x03})
+			m, _ := newReader(t, card)
+
+			dump, err := m.DumpClassic(mfrc522.KeyMap{Common: [][]byte{sim.DefaultKey}})
+			if err != nil {
+				t.Fatalf("DumpClassic() error = %v", err)
+			}
+			if len(dump.Blocks) != tt.blocks || !dump.Complete() {
+				t.Fatalf("DumpClassic() = %d blocks, complete: %t, want %d complete blocks",
+					len(dump.Blocks), dump.Complete(), tt.blocks)
+			}
+			if !bytes.Equal(dump.Bytes(), card.Dump()) {
+				t.Error("Bytes() differs from the card's content")
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package mfrc522
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Errors returned by the reader and the cards, which can be checked with errors.Is,
+// also when more context was added to them.
+var (
+	// ErrNoCard is returned when no card answered, or the expected card is not in the RF field anymore.
+	ErrNoCard = errors.New("no card answered")
+
+	// ErrTimeout is returned when the reader or the card didn't finish a command in time.
+	ErrTimeout = errors.New("timed out")
+
+	// ErrAuth is returned when the card refused the authentication with the key.
+	ErrAuth = errors.New("authentication failed")
+
+	// ErrParity is returned when the reader received a frame with a parity error.
+	ErrParity = errors.New("parity error")
+
+	// ErrCRC is returned when the CRC (or the BCC of a UUID) of a received frame is wrong.
+	ErrCRC = errors.New("CRC mismatch")
+
+	// ErrBufferOverflow is returned when the answer of the card didn't fit into the FIFO buffer.
+	ErrBufferOverflow = errors.New("FIFO buffer overflow")
+
+	// ErrProtocol is returned when a frame violates the protocol, e.g. because the card
+	// answered with an unexpected length.
+	ErrProtocol = errors.New("protocol error")
+)
+
+// errNoAnswer is returned when the reader's timer expired, because the tag didn't answer.
+// Callers that expect no answer (e.g. to HLTA) check for it with errors.Is.
+var errNoAnswer = wrap("tag didn't answer", ErrTimeout)
+
+// ErrCollision is returned when the bits of several cards collided in an answer.
+type ErrCollision struct {
+	// BitPos is the position of the first collided bit in the answer, starting at 1,
+	// or 0 if the reader couldn't determine it.
+	BitPos int
+}
+
+// Error returns a description of the collision.
+func (e ErrCollision) Error() string {
+	if e.BitPos == 0 {
+		return "bit collision"
+	}
+
+	return "bit collision at bit " + strconv.Itoa(e.BitPos)
+}
+
+// ErrNAK is returned when a MIFARE Classic card answers a command with a NAK instead of an ACK.
+type ErrNAK struct {
+	// Code is the 4-bit NAK code sent by the card.
+	Code byte
+}
+
+// Error returns a description of the NAK code.
+func (e ErrNAK) Error() string {
+	switch e.Code {
+	case 0x0:
+		return "tag answered NAK: invalid operation"
+	case 0x1:
+		return "tag answered NAK: parity or CRC error"
+	case 0x4:
+		return "tag answered NAK: invalid operation (transfer buffer valid)"
+	case 0x5:
+		return "tag answered NAK: parity or CRC error (transfer buffer valid)"
+	default:
+		return "tag answered NAK: code " + strconv.Itoa(int(e.Code))
+	}
+}
+
+// ErrMismatch is returned when a written block is read back with a different content.
+type ErrMismatch struct {
+	// Addr is the address of the block.
+	Addr byte
+}
+
+// Error returns a description of the mismatch.
+func (e ErrMismatch) Error() string {
+	return "block " + strconv.Itoa(int(e.Addr)) + " was read back with a different content"
+}
+
+// wrapError adds context to an error, which can still be checked with errors.Is and errors.As.
+type wrapError struct {
+	msg string
+	err error
+}
+
+// wrap returns err with the message in front of it.
+func wrap(msg string, err error) error {
+	return wrapError{msg: msg, err: err}
+}
+
+// Error returns the message followed by the wrapped error.
+func (e wrapError) Error() string {
+	return e.msg + ": " + e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e wrapError) Unwrap() error {
+	return e.err
+}
+
+// reselectError is returned when an operation failed and the card couldn't be selected
+// again afterwards. Both errors can be checked with errors.Is and errors.As.
+type reselectError struct {
+	err    error
+	selErr error
+}
+
+// Error returns both errors.
+func (e reselectError) Error() string {
+	return e.err.Error() + ", then failed to select card again: " + e.selErr.Error()
+}
+
+// Unwrap returns both errors.
+func (e reselectError) Unwrap() []error {
+	return []error{e.err, e.selErr}
+}
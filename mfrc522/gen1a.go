@@ -0,0 +1,180 @@
+package mfrc522
+
+import (
+	"context"
+	"errors"
+)
+
+// Gen1a is a "Chinese magic" Gen1a card in backdoor mode. Gen1a cards are MIFARE Classic
+// clones that answer an unlock sequence of non-standard commands, after which every block,
+// including block 0 with the UUID, can be read and written without authentication.
+type Gen1a struct {
+	s *Session
+}
+
+// UnlockGen1a halts the selected card and sends the Gen1a unlock sequence.
+// It returns an error if the card doesn't acknowledge the sequence, which means that it
+// is not a Gen1a card, or if the sequence fails. The card is then selected again, so the
+// session can still be used.
+func (s *Session) UnlockGen1a() (*Gen1a, error) {
+	return s.UnlockGen1aContext(context.Background())
+}
+
+// UnlockGen1aContext is like UnlockGen1a, but stops when the context is canceled or its deadline expires.
+func (s *Session) UnlockGen1aContext(ctx context.Context) (*Gen1a, error) {
+	if s.closed {
+		return nil, errors.New("session is closed")
+	}
+
+	ok, err := s.unlockGen1a(ctx)
+	if err == nil && ok {
+		return &Gen1a{s: s}, nil
+	}
+	if err == nil {
+		err = errors.New("not a Gen1a card")
+	}
+
+	if selErr := s.reselect(ctx); selErr != nil {
+		return nil, reselectError{err: err, selErr: selErr}
+	}
+
+	return nil, err
+}
+
+// IsGen1a reports whether the selected card is a Gen1a card. It tries to unlock the card
+// and selects it again afterwards, so the session can be used normally.
+func (s *Session) IsGen1a() (bool, error) {
+	return s.IsGen1aContext(context.Background())
+}
+
+// IsGen1aContext is like IsGen1a, but stops when the context is canceled or its deadline expires.
+func (s *Session) IsGen1aContext(ctx context.Context) (bool, error) {
+	if s.closed {
+		return false, errors.New("session is closed")
+	}
+
+	ok, err := s.unlockGen1a(ctx)
+	if selErr := s.reselect(ctx); selErr != nil {
+		if err != nil {
+			return false, reselectError{err: err, selErr: selErr}
+		}
+
+		return ok, selErr
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return ok, nil
+}
+
+// unlockGen1a halts the card and sends the unlock sequence (0x40 as 7 bits, then 0x43).
+// It reports whether the card acknowledged both commands.
+func (s *Session) unlockGen1a(ctx context.Context) (bool, error) {
+	// The backdoor commands are not encrypted
+	if err := s.m.halt(ctx); err != nil {
+		return false, err
+	}
+	if err := s.stopCrypto(); err != nil {
+		return false, err
+	}
+
+	res, _, err := s.m.transceiveBits(ctx, []byte{Gen1aUnlock1Cmd}, 7)
+	if err != nil || !isACK(res) {
+		return false, err
+	}
+
+	res, _, err = s.m.transceiveBits(ctx, []byte{Gen1aUnlock2Cmd}, 0)
+	if err != nil || !isACK(res) {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReadBlock reads the block at the address, without authentication.
+// Sector trailers are returned with their keys.
+func (g *Gen1a) ReadBlock(addr byte) ([]byte, error) {
+	return g.ReadBlockContext(context.Background(), addr)
+}
+
+// ReadBlockContext is like ReadBlock, but stops when the context is canceled or its deadline expires.
+func (g *Gen1a) ReadBlockContext(ctx context.Context, addr byte) ([]byte, error) {
+	if g.s.closed {
+		return nil, errors.New("session is closed")
+	}
+
+	return g.s.m.readTag(ctx, addr)
+}
+
+// WriteBlock writes data to the block at the address, without authentication.
+// Block 0 can be written as well, which changes the UUID of the card.
+func (g *Gen1a) WriteBlock(addr byte, data []byte) error {
+	return g.WriteBlockContext(context.Background(), addr, data)
+}
+
+// WriteBlockContext is like WriteBlock, but stops when the context is canceled or its deadline expires.
+func (g *Gen1a) WriteBlockContext(ctx context.Context, addr byte, data []byte) error {
+	if g.s.closed {
+		return errors.New("session is closed")
+	}
+
+	if err := g.s.m.writeTag(ctx, addr, data); err != nil {
+		return err
+	}
+
+	if addr == 0 {
+		g.s.setUUID(data)
+	}
+
+	return nil
+}
+
+// Wipe clears all blocks of the card, except for block 0, and resets the sector trailers
+// to the transport configuration (key A and key B FF FF FF FF FF FF, all access with key A).
+func (g *Gen1a) Wipe() error {
+	return g.WipeContext(context.Background())
+}
+
+// WipeContext is like Wipe, but stops when the context is canceled or its deadline expires.
+func (g *Gen1a) WipeContext(ctx context.Context) error {
+	sectors := g.s.card.Family().Sectors()
+	if sectors == 0 {
+		return errors.New("unknown card size: " + g.s.card.Family().String())
+	}
+
+	blank := make([]byte, 16)
+	trailer := []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0xFF, 0x07, 0x80, 0x69,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+	}
+
+	for sector := range byte(sectors) {
+		first := SectorFirstBlock(sector)
+		last := first + int(SectorBlocks(sector)) - 1
+
+		for addr := max(first, 1); addr <= last; addr++ {
+			data := blank
+			if addr == last {
+				data = trailer
+			}
+
+			if err := g.WriteBlockContext(ctx, byte(addr), data); err != nil {
+				return wrap("failed to wipe card", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close leaves the backdoor mode and selects the card again, so the session can be used normally.
+func (g *Gen1a) Close() error {
+	return g.CloseContext(context.Background())
+}
+
+// CloseContext is like Close, but stops when the context is canceled or its deadline expires.
+func (g *Gen1a) CloseContext(ctx context.Context) error {
+	return g.s.reselect(ctx)
+}
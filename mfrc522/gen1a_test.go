@@ -0,0 +1,286 @@
+package mfrc522_test
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+	"github.com/msthtrifork/gorfid/mfrc522/sim"
+)
+
+// transportTrailer is the sector trailer that Wipe writes.
+var transportTrailer = [16]byte{
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+	0xFF, 0x07, 0x80, 0x69,
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+}
+
+// noiseTarget is another card in the RF field that answers the second command of the
+// Gen1a unlock sequence, so the answer of a Gen1a card collides with it.
+type noiseTarget struct{}
+
+// Transceive answers the second unlock command with a NAK.
+func (noiseTarget) Transceive(f sim.Frame) *sim.Frame {
+	if f.Bits == 8 && f.Data[0] == mfrc522.Gen1aUnlock2Cmd {
+		return &sim.Frame{Data: []byte{0x05}, Bits: 4}
+	}
+
+	return nil
+}
+
+// Reset does nothing.
+func (noiseTarget) Reset() {}
+
+// leavingCard is a card that leaves the RF field after it received the second command of the
+// Gen1a unlock sequence.
+type leavingCard struct {
+	*sim.Classic
+
+	mu   sync.Mutex
+	gone bool
+}
+
+// Transceive passes the frames on to the card until it left the RF field.
+func (c *leavingCard) Transceive(f sim.Frame) *sim.Frame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gone {
+		return nil
+	}
+	if f.Bits == 8 && f.Data[0] == mfrc522.Gen1aUnlock2Cmd {
+		c.gone = true
+	}
+
+	return c.Classic.Transceive(f)
+}
+
+// newMagicCard creates a blank simulated card that emulates the magic card.
+func newMagicCard(t *testing.T, typ sim.ClassicType, magic sim.Magic) *sim.Classic {
+	t.Helper()
+
+	card := newCard(t, typ, 0xDE, 0xAD, 0xBE, 0xEF)
+	card.SetMagic(magic)
+
+	return card
+}
+
+// checkSelected checks that the session can still be used to read block 4 of the card.
+func checkSelected(t *testing.T, s *mfrc522.Session, card *sim.Classic) {
+	t.Helper()
+
+	if s.Closed() {
+		t.Fatal("Closed() = true, want the card selected again")
+	}
+	if err := s.Authenticate(mfrc522.AuthKeyACmd, 4, sim.DefaultKey); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	data, err := s.ReadBlock(4)
+	if err != nil {
+		t.Fatalf("ReadBlock() error = %v", err)
+	}
+	if want := card.Block(4); !bytes.Equal(data, want[:]) {
+		t.Errorf("ReadBlock() = % x, want % x", data, want)
+	}
+}
+
+func TestUnlockGen1a(t *testing.T) {
+	keyA := []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}
+	card := newMagicCard(t, sim.Classic1K, sim.MagicGen1a)
+	card.SetTrailer(1, keyA, sim.TransportAccessBits, sim.DefaultKey)
+	card.SetBlock(4, [16]byte{0x01, 0x02, 0x03})
+	m, _ := newReader(t, card)
+	s := selectCard(t, m)
+
+	g, err := s.UnlockGen1a()
+	if err != nil {
+		t.Fatalf("UnlockGen1a() error = %v", err)
+	}
+
+	// Every block can be read without authentication, and the trailers with their keys
+	for _, addr := range []byte{0, 4, 7} {
+		data, err := g.ReadBlock(addr)
+		if err != nil {
+			t.Fatalf("ReadBlock(%d) error = %v", addr, err)
+		}
+		if want := card.Block(int(addr)); !bytes.Equal(data, want[:]) {
+			t.Errorf("ReadBlock(%d) = % x, want % x", addr, data, want)
+		}
+	}
+
+	// Writing block 0 changes the UUID
+	uid := []byte{0x01, 0x02, 0x03, 0x04}
+	block0 := card.Block(0)
+	copy(block0[:], uid)
+	block0[4] = uid[0] ^ uid[1] ^ uid[2] ^ uid[3]
+	if err := g.WriteBlock(0, block0[:]); err != nil {
+		t.Fatalf("WriteBlock(0) error = %v", err)
+	}
+	data := bytes.Repeat([]byte{0x5A}, 16)
+	if err := g.WriteBlock(5, data); err != nil {
+		t.Fatalf("WriteBlock(5) error = %v", err)
+	}
+	if block5 := card.Block(5); card.Block(0) != block0 || !bytes.Equal(block5[:], data) {
+		t.Errorf("blocks 0 and 5 = % x and % x, want % x and % x", card.Block(0), block5, block0, data)
+	}
+
+	if err := g.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !bytes.Equal(s.UUID(), uid) {
+		t.Errorf("UUID() after writing block 0 = % x, want % x", s.UUID(), uid)
+	}
+	if err := s.Authenticate(mfrc522.AuthKeyACmd, 4, keyA); err != nil {
+		t.Errorf("Authenticate() after Close() error = %v", err)
+	}
+}
+
+func TestUnlockGen1aGenuine(t *testing.T) {
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	card.SetBlock(4, [16]byte{0x01, 0x02, 0x03})
+	m, _ := newReader(t, card)
+	s := selectCard(t, m)
+
+	if _, err := s.UnlockGen1a(); err == nil {
+		t.Fatal("UnlockGen1a() of a genuine card error = nil, want an error")
+	}
+	checkSelected(t, s, card)
+}
+
+func TestIsGen1a(t *testing.T) {
+	tests := []struct {
+		name  string
+		magic sim.Magic
+		want  bool
+	}{
+		{"Gen1a", sim.MagicGen1a, true},
+		{"genuine", sim.MagicNone, false},
+		{"Gen2", sim.MagicGen2, false},
+		{"Gen4", sim.MagicGen4, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := newMagicCard(t, sim.Classic1K, tt.magic)
+			card.SetBlock(4, [16]byte{0x01, 0x02, 0x03})
+			m, _ := newReader(t, card)
+			s := selectCard(t, m)
+
+			ok, err := s.IsGen1a()
+			if err != nil {
+				t.Fatalf("IsGen1a() error = %v", err)
+			}
+			if ok != tt.want {
+				t.Errorf("IsGen1a() = %t, want %t", ok, tt.want)
+			}
+			checkSelected(t, s, card)
+		})
+	}
+}
+
+func TestUnlockGen1aError(t *testing.T) {
+	calls := []struct {
+		name string
+		call func(s *mfrc522.Session) error
+	}{
+		{"UnlockGen1a", func(s *mfrc522.Session) error {
+			_, err := s.UnlockGen1a()
+			return err
+		}},
+		{"IsGen1a", func(s *mfrc522.Session) error {
+			_, err := s.IsGen1a()
+			return err
+		}},
+		{"DetectMagic", func(s *mfrc522.Session) error {
+			_, err := s.DetectMagic(mfrc522.MagicOptions{})
+			return err
+		}},
+	}
+
+	for _, c := range calls {
+		t.Run(c.name, func(t *testing.T) {
+			// The card is selected again after the failed unlock sequence
+			card := newMagicCard(t, sim.Classic1K, sim.MagicGen1a)
+			card.SetBlock(4, [16]byte{0x01, 0x02, 0x03})
+			m, _ := newReader(t, card, noiseTarget{})
+			s := selectCard(t, m)
+
+			var coll mfrc522.ErrCollision
+			if err := c.call(s); !errors.As(err, &coll) {
+				t.Fatalf("%s() error = %v, want ErrCollision", c.name, err)
+			}
+			checkSelected(t, s, card)
+		})
+
+		t.Run(c.name+" lost card", func(t *testing.T) {
+			// Both errors are returned if the card can't be selected again
+			card := &leavingCard{Classic: newMagicCard(t, sim.Classic1K, sim.MagicGen1a)}
+			m, _ := newReader(t, card, noiseTarget{})
+			s := selectCard(t, m)
+
+			var coll mfrc522.ErrCollision
+			err := c.call(s)
+			if !errors.As(err, &coll) || !errors.Is(err, mfrc522.ErrNoCard) {
+				t.Fatalf("%s() error = %v, want ErrCollision and ErrNoCard", c.name, err)
+			}
+			if !s.Closed() {
+				t.Error("Closed() = false after the card was lost, want true")
+			}
+		})
+	}
+}
+
+func TestGen1aWipe(t *testing.T) {
+	tests := []struct {
+		name   string
+		typ    sim.ClassicType
+		family mfrc522.CardFamily
+		blocks int
+	}{
+		{"mini", sim.ClassicMini, mfrc522.FamilyClassicMini, 20},
+		{"1K", sim.Classic1K, mfrc522.FamilyClassic1K, 64},
+		{"4K", sim.Classic4K, mfrc522.FamilyClassic4K, 256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := newMagicCard(t, tt.typ, sim.MagicGen1a)
+			block0 := card.Block(0)
+			for addr := 1; addr < tt.blocks; addr++ {
+				card.SetBlock(addr, [16]byte{byte(addr), 0xAA, 0x55})
+			}
+			m, _ := newReader(t, card)
+			s := selectCard(t, m)
+
+			g, err := s.UnlockGen1a()
+			if err != nil {
+				t.Fatalf("UnlockGen1a() error = %v", err)
+			}
+			if err := g.Wipe(); err != nil {
+				t.Fatalf("Wipe() error = %v", err)
+			}
+			if err := g.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if card.Block(0) != block0 {
+				t.Errorf("block 0 after Wipe() = % x, want % x", card.Block(0), block0)
+			}
+			for sector := range byte(tt.family.Sectors()) {
+				first := mfrc522.SectorFirstBlock(sector)
+				last := first + int(mfrc522.SectorBlocks(sector)) - 1
+				for addr := max(first, 1); addr <= last; addr++ {
+					want := [16]byte{}
+					if addr == last {
+						want = transportTrailer
+					}
+					if got := card.Block(addr); got != want {
+						t.Errorf("block %d after Wipe() = % x, want % x", addr, got, want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -1,41 +1,11 @@
 package mfrc522
 
 import (
+	"context"
 	"errors"
 	"time"
 )
 
-// ReadRegisterBytes allows reading multiple bytes from a register.
-func (m *MFRC522) ReadRegisterBytes(reg Register, readLen int) ([]byte, error) {
-	if readLen < 1 {
-		return nil, nil
-	}
-
-	data := make([]byte, 0, readLen+1)
-	for range readLen {
-		data = append(data, 0x80|reg)
-	}
-	data = append(data, 0)
-
-	res := make([]byte, len(data))
-	if err := m.spi.Tx(data, res); err != nil {
-		return nil, err
-	}
-
-	return res[1:], nil
-}
-
-// WriteRegisterBytes allows writing multiple bytes to a register.
-func (m *MFRC522) WriteRegisterBytes(reg Register, val []byte) error {
-	data := append([]byte{reg}, val...)
-
-	if err := m.spi.Tx(data, nil); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 // WriteSequence is a convenience function for writing predefined
 // sequences of commands to registers.
 func (m *MFRC522) WriteSequence(commands []WriteCommand) error {
@@ -49,44 +19,103 @@ func (m *MFRC522) WriteSequence(commands []WriteCommand) error {
 	return nil
 }
 
-// selectCard sets the detected card as selected in the reader and returns its UUID.
-func (m *MFRC522) selectCard() ([]byte, error) {
+// selectCard sets the detected card as selected in the reader and returns its identification.
+func (m *MFRC522) selectCard(ctx context.Context) (CardInfo, error) {
 	defer func() { _ = m.ClearIRQ() }()
 
-	if err := m.WaitForInterrupt(m.irqTimeout); err != nil {
-		return nil, err
+	if err := m.WaitForInterruptContext(ctx, m.irqTimeout); err != nil {
+		return CardInfo{}, err
 	}
 
-	// Needed to set bit-framing register and clear FIFO buffer
-	if _, err := m.numTagBlocks(); err != nil {
-		return nil, err
+	// WUPA also wakes up cards that were halted, e.g. by Watch
+	atqa, ok, err := m.request(ctx, WakeUpACmd)
+	if err != nil {
+		return CardInfo{}, err
+	}
+	if !ok {
+		return CardInfo{}, ErrNoCard
 	}
 
-	uuid, err := m.antiCollision()
+	uuid, sak, err := m.cascade(ctx)
 	if err != nil {
-		return nil, err
+		return CardInfo{}, err
 	}
 
-	_, err = m.selectUUID(uuid)
-	if err != nil {
-		return nil, err
+	return CardInfo{UUID: uuid, ATQA: atqa, SAK: sak}, nil
+}
+
+// cascade runs the anti-collision and selection for each cascade level (ISO 14443-3, section 6.5.3),
+// until the card reports that its UUID is complete. It returns the UUID and the SAK of the last level.
+func (m *MFRC522) cascade(ctx context.Context) ([]byte, byte, error) {
+	var uuid []byte
+	for _, level := range cascadeLevels {
+		part, err := m.antiCollision(ctx, level)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		sak, err := m.selectUUID(ctx, level, part)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// The cascade bit in the SAK signals that the UUID is not complete yet
+		if sak&0x04 == 0 {
+			return append(uuid, part[:4]...), sak, nil
+		}
+
+		if part[0] != CascadeTagCmd {
+			return nil, 0, wrap("missing cascade tag", ErrProtocol)
+		}
+		uuid = append(uuid, part[1:4]...)
 	}
 
-	if uuid[0] == 0x88 {
-		// Some tags have longer UIDs, so the remaining bytes need to be read separately,
-		// but this is not supported for this lab exercise.
-		return nil, errors.New("tag not supported")
+	return nil, 0, wrap("UUID not complete after 3 cascade levels", ErrProtocol)
+}
+
+// selectKnown selects the tag with the known UUID on each cascade level, without the anti-collision,
+// and returns the SAK of the last level. Only the tag with that UUID answers, even if others are present.
+func (m *MFRC522) selectKnown(ctx context.Context, uuid []byte) (byte, error) {
+	var parts [][]byte
+	switch len(uuid) {
+	case 4:
+		parts = [][]byte{uuid}
+	case 7:
+		parts = [][]byte{{CascadeTagCmd, uuid[0], uuid[1], uuid[2]}, uuid[3:]}
+	case 10:
+		parts = [][]byte{{CascadeTagCmd, uuid[0], uuid[1], uuid[2]}, {CascadeTagCmd, uuid[3], uuid[4], uuid[5]}, uuid[6:]}
+	default:
+		return 0, errors.New("invalid UUID length, expected 4, 7 or 10 bytes")
+	}
+
+	var sak byte
+	for i, part := range parts {
+		bcc := part[0] ^ part[1] ^ part[2] ^ part[3]
+
+		var err error
+		sak, err = m.selectUUID(ctx, cascadeLevels[i], append(append([]byte(nil), part...), bcc))
+		if err != nil {
+			return 0, err
+		}
+		if (sak&0x04 != 0) != (i < len(parts)-1) {
+			return 0, wrap("UUID length doesn't match the cascade levels", ErrProtocol)
+		}
 	}
 
-	return uuid[:len(uuid)-1], nil
+	return sak, nil
 }
 
 // authenticate authenticates an address (sector+block) for the selected tag.
-func (m *MFRC522) authenticate(authMode, addr byte, key, uuid []byte) (AuthStatus, error) {
+func (m *MFRC522) authenticate(ctx context.Context, authMode, addr byte, key, uuid []byte) (AuthStatus, error) {
+	// Cards with longer UUIDs use the last 4 bytes for authentication
 	data := append([]byte{authMode, addr}, key...)
-	data = append(data, uuid[:4]...)
+	data = append(data, uuid[len(uuid)-4:]...)
 
-	_, err := m.writeTagCommand(MFAuthentCmd, data)
+	// A tag that doesn't accept the key stops answering
+	_, err := m.writeTagCommand(ctx, MFAuthentCmd, data)
+	if errors.Is(err, errNoAnswer) {
+		return AuthFail, nil
+	}
 	if err != nil {
 		return AuthReadFail, err
 	}
@@ -100,10 +129,13 @@ func (m *MFRC522) authenticate(authMode, addr byte, key, uuid []byte) (AuthStatu
 }
 
 // crc calculates the CRC of the given data (on the reader).
-func (m *MFRC522) crc(data []byte) ([]byte, error) {
+func (m *MFRC522) crc(ctx context.Context, data []byte) ([]byte, error) {
+	// Only the CRC coprocessor drives the interrupt pin
 	if err := m.WriteSequence([]WriteCommand{
 		{CommandReg, IdleCmd},
+		{ComIEnReg, 0x80},
 		{DivIEnReg, 0x04},
+		{DivIrqReg, 0x04},
 		{FIFOLevelReg, 0x80},
 	}); err != nil {
 		return nil, err
@@ -115,212 +147,449 @@ func (m *MFRC522) crc(data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	for range 100 {
-		val, err := m.ReadRegister(DivIrqReg)
-		if err != nil {
-			return nil, err
-		}
-
-		if val&0x04 != 0 {
-			if err = m.WriteRegister(CommandReg, IdleCmd); err != nil {
-				return nil, err
-			}
-
-			crc := make([]byte, 2)
-			crc[0], err = m.ReadRegister(CRCResultLowReg)
-			if err != nil {
-				return nil, err
-			}
-
-			crc[1], err = m.ReadRegister(CRCResultHighReg)
-			if err != nil {
-				return nil, err
-			}
+	if _, err := m.waitForIRQ(ctx, DivIrqReg, 0x04, 100*time.Millisecond, m.commandPollInterval); err != nil {
+		return nil, err
+	}
 
-			return crc, nil
-		}
+	// Clearing the interrupt releases the interrupt pin for the next command
+	if err := m.WriteSequence([]WriteCommand{
+		{CommandReg, IdleCmd},
+		{DivIEnReg, 0x00},
+		{DivIrqReg, 0x04},
+	}); err != nil {
+		return nil, err
+	}
 
-		time.Sleep(1 * time.Millisecond)
+	lo, err := m.ReadRegister(CRCResultLowReg)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := m.ReadRegister(CRCResultHighReg)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, errors.New("timed out while calculating CRC")
+	return []byte{lo, hi}, nil
 }
 
 // verifyCRC calculates the CRC and sends it to the tag for verification.
-func (m *MFRC522) verifyCRC(cmd, addr byte) ([]byte, error) {
-	crc, err := m.crc([]byte{cmd, addr})
+func (m *MFRC522) verifyCRC(ctx context.Context, cmd, addr byte) ([]byte, error) {
+	crc, err := m.crc(ctx, []byte{cmd, addr})
 	if err != nil {
 		return nil, err
 	}
 
-	return m.writeTagCommand(TransceiveCmd, []byte{cmd, addr, crc[0], crc[1]})
+	return m.writeTagCommand(ctx, TransceiveCmd, []byte{cmd, addr, crc[0], crc[1]})
 }
 
 // readTag reads the address (sector+block) from the selected tag.
-func (m *MFRC522) readTag(addr byte) ([]byte, error) {
-	data, err := m.verifyCRC(ReadBlockCmd, addr)
+func (m *MFRC522) readTag(ctx context.Context, addr byte) ([]byte, error) {
+	data, err := m.verifyCRC(ctx, ReadBlockCmd, addr)
 	if err != nil {
 		return nil, err
 	}
-	if len(data) != 16 {
-		return nil, errors.New("invalid data length, expected 16 bytes")
+
+	// A card that refuses the read answers with a 4-bit NAK instead of the data
+	if len(data) == 1 && !isACK(data) {
+		return nil, ErrNAK{Code: data[0] & 0x0F}
+	}
+	if len(data) != 18 {
+		return nil, wrap("invalid data length, expected 18 bytes", ErrProtocol)
 	}
 
-	return data, nil
+	// The reader doesn't remove the CRC, so it needs to be checked here
+	crc, err := m.crc(ctx, data[:16])
+	if err != nil {
+		return nil, err
+	}
+	if crc[0] != data[16] || crc[1] != data[17] {
+		return nil, ErrCRC
+	}
+
+	return data[:16], nil
 }
 
-// writeTag writes data to the address (sector+block) on the selected tag.
-func (m *MFRC522) writeTag(addr byte, data []byte) error {
-	data, err := m.verifyCRC(WriteBlockCmd, addr)
+// writeTag writes the 16 bytes of data to the address (sector+block) on the selected tag.
+// The WRITE is sent in two phases: first the address, then the data, each answered with an ACK.
+func (m *MFRC522) writeTag(ctx context.Context, addr byte, data []byte) error {
+	if len(data) != 16 {
+		return errors.New("invalid data length, expected 16 bytes")
+	}
+
+	ack, err := m.verifyCRC(ctx, WriteBlockCmd, addr)
 	if err != nil {
 		return err
 	}
-	if data[0]&0x0F != 0x0A {
-		return errors.New("couldn't authorize write operation")
+	if err := checkACK(ack); err != nil {
+		return err
 	}
 
-	crc, err := m.crc(data[:16])
+	crc, err := m.crc(ctx, data)
 	if err != nil {
 		return err
 	}
 
-	writeData := append(data[:16], crc...)
-	data, err = m.writeTagCommand(WriteBlockCmd, writeData)
+	ack, err = m.writeTagCommand(ctx, TransceiveCmd, append(append([]byte(nil), data...), crc...))
 	if err != nil {
 		return err
 	}
 
-	if data[0]&0x0F != 0x0A {
-		return errors.New("write operation failed")
+	return checkACK(ack)
+}
+
+// writeTagCommand writes a command to the tag and returns the response.
+func (m *MFRC522) writeTagCommand(ctx context.Context, cmd RegisterCommand, data []byte) ([]byte, error) {
+	errStatus, err := m.executeTagCommand(ctx, cmd, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.commandError(errStatus & 0x1B); err != nil {
+		return nil, err
+	}
+
+	if cmd == TransceiveCmd {
+		res, _, err := m.readFIFO()
+		return res, err
 	}
 
-	return nil
+	return nil, nil
 }
 
-// writeTagCommand writes a command to the tag and returns the response.
-func (m *MFRC522) writeTagCommand(cmd RegisterCommand, data []byte) ([]byte, error) {
-	var irqEn, irqWait byte
+// tagTimeout is the maximum time to wait for a command that communicates with the tag.
+// It is twice the time of the reader's timer (25 ms, see InitSequence), which ends the
+// command if the tag doesn't answer.
+const tagTimeout = 50 * time.Millisecond
+
+// executeTagCommand runs a command that communicates with the tag and returns the value
+// of the error register, so the caller can decide which errors to tolerate.
+//
+// The reader's timer starts when the data was sent, and errNoAnswer is returned if it expires
+// before the command finished, which means that the tag didn't answer.
+func (m *MFRC522) executeTagCommand(ctx context.Context, cmd RegisterCommand, data []byte) (byte, error) {
+	var irqWait byte
 	switch cmd {
 	case MFAuthentCmd:
-		irqEn = 0x12
 		irqWait = 0x10
 	case TransceiveCmd:
-		irqEn = 0x77
 		irqWait = 0x30
 	}
 
-	if err := m.WriteRegister(ComIEnReg, irqEn|0x80); err != nil {
-		return nil, err
+	// Only the end of the command and the timer drive the interrupt pin
+	if err := m.WriteRegister(ComIEnReg, irqWait|0x01|0x80); err != nil {
+		return 0, err
 	}
 	if err := m.ClearBitmask(ComIrqReg, 0x80); err != nil {
-		return nil, err
+		return 0, err
 	}
 	if err := m.SetBitmask(FIFOLevelReg, 0x80); err != nil {
-		return nil, err
+		return 0, err
 	}
 	if err := m.WriteRegister(CommandReg, IdleCmd); err != nil {
-		return nil, err
+		return 0, err
 	}
 	if err := m.WriteRegisterBytes(FIFODataReg, data); err != nil {
-		return nil, err
+		return 0, err
 	}
 	if err := m.WriteRegister(CommandReg, cmd); err != nil {
-		return nil, err
+		return 0, err
 	}
 
 	if cmd == TransceiveCmd {
 		if err := m.SetBitmask(BitFramingReg, 0x80); err != nil {
-			return nil, err
+			return 0, err
 		}
 	}
 
-	// Wait for data to be sent
-	for range 2000 {
-		val, err := m.ReadRegister(ComIrqReg)
+	irq, err := m.waitForIRQ(ctx, ComIrqReg, irqWait|0x01, tagTimeout, m.commandPollInterval)
+
+	if err := m.ClearBitmask(BitFramingReg, 0x80); err != nil {
+		return 0, err
+	}
+	if err != nil || irq&irqWait == 0 {
+		// Stop the command, so it doesn't continue in the background
+		if err := m.WriteRegister(CommandReg, IdleCmd); err != nil {
+			return 0, err
+		}
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
 
-		if val&(irqWait|0x01) != 0x00 {
-			break
-		}
+		return 0, errNoAnswer
 	}
 
-	if err := m.ClearBitmask(BitFramingReg, 0x80); err != nil {
-		return nil, err
-	}
+	return m.ReadRegister(ErrorReg)
+}
 
-	errStatus, err := m.ReadRegister(ErrorReg)
-	if err != nil || errStatus&0x1B != 0 {
-		return nil, errors.New("error during command execution")
+// waitForIRQ waits until one of the bits is set in the interrupt request register (ComIrqReg
+// or DivIrqReg) and returns the value of the register. With an interrupt pin, it sleeps until
+// the level of the pin changes, otherwise the register is polled with the interval.
+// It returns ErrTimeout if none of the bits was set within the timeout.
+func (m *MFRC522) waitForIRQ(ctx context.Context, reg Register, bits byte, timeout, interval time.Duration) (byte, error) {
+	// Changes of the pin before this point are already visible in the register
+	select {
+	case <-m.irq:
+	default:
 	}
 
-	if errStatus&irqEn&0x01 == 1 {
-		return nil, errors.New("invalid interrupt request")
-	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
-	if cmd == TransceiveCmd {
-		level, err := m.ReadRegister(FIFOLevelReg)
+	for {
+		val, err := m.ReadRegister(reg)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
-
-		reg, err := m.ReadRegister(ControlReg)
-		if err != nil {
-			return nil, err
+		if val&bits != 0 {
+			return val, nil
 		}
 
-		var dataLen int
-		if reg&0x07 != 0x00 {
-			dataLen = (int(level)-1)*8 + int(reg&0x07)
-		} else {
-			dataLen = int(level) * 8
+		// Receiving from the nil channel blocks, so only the pin or the poll timer wakes up the loop
+		var poll <-chan time.Time
+		if m.irq == nil {
+			poll = time.After(interval)
 		}
 
-		if level == 0 {
-			level = 1
-		} else if level > 16 {
-			level = 16
+		select {
+		case <-m.irq:
+		case <-poll:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-timer.C:
+			return 0, wrap("waiting for the reader", ErrTimeout)
 		}
+	}
+}
 
-		res, err := m.ReadRegisterBytes(FIFODataReg, dataLen)
-		if err != nil {
-			return nil, err
-		}
+// readFIFO reads the tag's response from the FIFO buffer and returns it together
+// with the number of valid bits in its last byte (0 if the whole byte is valid).
+func (m *MFRC522) readFIFO() ([]byte, byte, error) {
+	level, err := m.ReadRegister(FIFOLevelReg)
+	if err != nil {
+		return nil, 0, err
+	}
 
-		return res, nil
+	res, err := m.ReadRegisterBytes(FIFODataReg, int(level))
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return nil, nil
+	lastBits, err := m.ReadRegister(ControlReg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return res, lastBits & 0x07, nil
 }
 
-// numTagBlocks returns the number of blocks in the tag.
-func (m *MFRC522) numTagBlocks() (int, error) {
-	if err := m.WriteRegister(BitFramingReg, 0x07); err != nil {
-		return -1, err
+// transceiveBits sends a raw frame to the tag, without a CRC, and returns the answer together
+// with the number of valid bits in its last byte, or nil if the tag didn't answer. lastBits is the
+// number of bits sent from the last byte of data (0 if the whole byte is sent), which allows
+// sending short frames.
+func (m *MFRC522) transceiveBits(ctx context.Context, data []byte, lastBits byte) ([]byte, byte, error) {
+	if err := m.WriteRegister(BitFramingReg, lastBits&0x07); err != nil {
+		return nil, 0, err
 	}
 
-	data, err := m.writeTagCommand(TransceiveCmd, []byte{0x26})
+	errStatus, err := m.executeTagCommand(ctx, TransceiveCmd, data)
+	if errors.Is(err, errNoAnswer) {
+		return nil, 0, m.WriteRegister(BitFramingReg, 0x00)
+	}
 	if err != nil {
-		return -1, err
+		return nil, 0, err
+	}
+	if err := m.commandError(errStatus & 0x1B); err != nil {
+		return nil, 0, err
 	}
 
-	return len(data), nil
-}
+	res, bits, err := m.readFIFO()
+	if err != nil {
+		return nil, 0, err
+	}
 
-// antiCollision performs the anti-collision procedure and returns the UUID of the selected tag.
-func (m *MFRC522) antiCollision() ([]byte, error) {
 	if err := m.WriteRegister(BitFramingReg, 0x00); err != nil {
+		return nil, 0, err
+	}
+
+	return res, bits, nil
+}
+
+// transceiveCRC sends the data with a CRC to the tag and returns the answer without its CRC,
+// or nil if the tag didn't answer.
+func (m *MFRC522) transceiveCRC(ctx context.Context, data []byte) ([]byte, error) {
+	crc, err := m.crc(ctx, data)
+	if err != nil {
 		return nil, err
 	}
 
-	data, err := m.writeTagCommand(TransceiveCmd, []byte{AntiCollSelect1Cmd, 0x20})
+	res, err := m.writeTagCommand(ctx, TransceiveCmd, append(append([]byte(nil), data...), crc...))
+	if errors.Is(err, errNoAnswer) {
+		return nil, nil
+	}
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	if len(res) < 3 {
+		return nil, wrap("invalid data length, expected at least 3 bytes", ErrProtocol)
+	}
+
+	crc, err = m.crc(ctx, res[:len(res)-2])
+	if err != nil {
+		return nil, err
+	}
+	if crc[0] != res[len(res)-2] || crc[1] != res[len(res)-1] {
+		return nil, ErrCRC
+	}
+
+	return res[:len(res)-2], nil
+}
+
+// isACK reports whether the answer is the 4-bit ACK of a MIFARE Classic card.
+func isACK(res []byte) bool {
+	return len(res) == 1 && res[0]&0x0F == 0x0A
+}
+
+// checkACK returns nil if the answer is an ACK, and ErrNAK with the code if it is a NAK.
+// A card that doesn't answer at all returns ErrTimeout.
+func checkACK(res []byte) error {
+	if len(res) == 0 {
+		return wrap("tag didn't acknowledge", ErrTimeout)
+	}
+	if len(res) != 1 {
+		return wrap("invalid answer length, expected a 4-bit ACK", ErrProtocol)
+	}
+	if !isACK(res) {
+		return ErrNAK{Code: res[0] & 0x0F}
+	}
+
+	return nil
+}
+
+// request sends REQA or WUPA (cmd) and returns the ATQA of the cards that answered.
+// If several cards answer, the ATQA has the bits of all their answers set.
+// It reports false if no card answered.
+func (m *MFRC522) request(ctx context.Context, cmd TagCommand) (uint16, bool, error) {
+	// Keep the bits received after a collision, which were cleared by the anti-collision
+	if err := m.SetBitmask(CollReg, 0x80); err != nil {
+		return 0, false, err
+	}
+	if err := m.WriteRegister(BitFramingReg, 0x07); err != nil {
+		return 0, false, err
+	}
+
+	// Collisions are expected if multiple cards are present
+	errStatus, err := m.executeTagCommand(ctx, TransceiveCmd, []byte{cmd})
+	if errors.Is(err, errNoAnswer) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if err := m.commandError(errStatus & 0x13); err != nil {
+		return 0, false, err
+	}
+
+	res, _, err := m.readFIFO()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch len(res) {
+	case 0:
+		return 0, false, nil
+	case 2:
+		return uint16(res[0]) | uint16(res[1])<<8, true, nil
+	default:
+		return 0, false, wrap("invalid data length, expected 2 bytes", ErrProtocol)
+	}
+}
+
+// halt sends HLTA to the selected tag, which puts it into the HALT state.
+// The tag only answers if it didn't understand the command.
+func (m *MFRC522) halt(ctx context.Context) error {
+	crc, err := m.crc(ctx, []byte{HaltACmd, 0x00})
+	if err != nil {
+		return err
+	}
+
+	res, err := m.writeTagCommand(ctx, TransceiveCmd, []byte{HaltACmd, 0x00, crc[0], crc[1]})
+	if errors.Is(err, errNoAnswer) {
+		return nil
+	}
 	if err != nil {
+		return err
+	}
+	if len(res) != 0 {
+		return wrap("tag refused to halt", ErrProtocol)
+	}
+
+	return nil
+}
+
+// antiCollision performs the anti-collision procedure for the cascade level
+// and returns the part of the UUID of the selected tag, followed by the BCC.
+//
+// If several tags answer, the reader sends the bits that are known so far and the
+// tags that match them answer with the rest, until the first bit where their UUIDs
+// differ (ISO 14443-3, section 6.5.3). The procedure continues with the tags that
+// have that bit set, until a single tag is left.
+func (m *MFRC522) antiCollision(ctx context.Context, level TagCommand) ([]byte, error) {
+	// Bits received after a collision are cleared, so they can be merged with the known bits
+	if err := m.ClearBitmask(CollReg, 0x80); err != nil {
 		return nil, err
 	}
 
-	if len(data) != 5 {
-		return nil, errors.New("invalid data length, expected 5 bytes")
+	data := make([]byte, 5)
+	known := 0
+	for known < len(data)*8 {
+		// The reader sends the known bits and the tag answers with the rest,
+		// starting in the same (partial) byte
+		lastBits := byte(known % 8)
+		if err := m.WriteRegister(BitFramingReg, lastBits<<4|lastBits); err != nil {
+			return nil, err
+		}
+
+		nvb := byte(2+known/8)<<4 | lastBits
+		frame := append([]byte{level, nvb}, data[:(known+7)/8]...)
+
+		errStatus, err := m.executeTagCommand(ctx, TransceiveCmd, frame)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.commandError(errStatus & 0x13); err != nil {
+			return nil, err
+		}
+
+		res, _, err := m.readFIFO()
+		if err != nil {
+			return nil, err
+		}
+		if len(res) == 0 || known/8+len(res) != len(data) {
+			return nil, wrap("invalid data length, expected 5 bytes", ErrProtocol)
+		}
+
+		mask := byte(0xFF) << lastBits
+		data[known/8] = data[known/8]&^mask | res[0]&mask
+		copy(data[known/8+1:], res[1:])
+
+		if errStatus&0x08 == 0 {
+			break
+		}
+
+		// The collision position starts at 1 with the first bit in the FIFO,
+		// including the bits skipped because of the alignment
+		pos, err := m.collisionPos()
+		if err != nil {
+			return nil, err
+		}
+
+		bit := known/8*8 + pos - 1
+		if pos == 0 || bit < known {
+			return nil, ErrCollision{BitPos: pos}
+		}
+
+		// Continue with the tags that have the bit set
+		data[bit/8] |= 1 << (bit % 8)
+		known = bit + 1
 	}
 
 	var crc byte
@@ -328,45 +597,86 @@ func (m *MFRC522) antiCollision() ([]byte, error) {
 		crc = crc ^ data[i]
 	}
 	if crc != data[4] {
-		return nil, errors.New("CRC mismatch")
+		return nil, wrap("invalid BCC", ErrCRC)
 	}
 
 	return data, nil
 }
 
-// selectUUID selects the tag with the given UUID.
-func (m *MFRC522) selectUUID(uuid []byte) (byte, error) {
-	data := append([]byte{AntiCollSelect1Cmd, 0x70}, uuid...)
+// selectUUID selects the tag with the given part of the UUID on the cascade level and returns its SAK.
+func (m *MFRC522) selectUUID(ctx context.Context, level TagCommand, uuid []byte) (byte, error) {
+	if err := m.WriteRegister(BitFramingReg, 0x00); err != nil {
+		return 0, err
+	}
 
-	crc, err := m.crc(data)
+	data := append([]byte{level, 0x70}, uuid...)
+
+	crc, err := m.crc(ctx, data)
 	if err != nil {
 		return 0, err
 	}
 
 	data = append(data, crc...)
-	res, err := m.writeTagCommand(TransceiveCmd, data)
+	res, err := m.writeTagCommand(ctx, TransceiveCmd, data)
 	if err != nil {
 		return 0, err
 	}
 
-	var selectData byte
-	if len(res) != 0x18 {
-		selectData = res[0]
-	} else {
-		selectData = 0
+	if len(res) != 3 {
+		return 0, wrap("invalid data length, expected 3 bytes", ErrProtocol)
 	}
 
-	return selectData, nil
+	crc, err = m.crc(ctx, res[:1])
+	if err != nil {
+		return 0, err
+	}
+	if crc[0] != res[1] || crc[1] != res[2] {
+		return 0, ErrCRC
+	}
+
+	return res[0], nil
+}
+
+// commandError returns the error for the first bit set in the value of the error register
+// (BufferOvfl, CollErr, CRCErr, ParityErr, ProtocolErr), or nil if none of them is set.
+func (m *MFRC522) commandError(errStatus byte) error {
+	switch {
+	case errStatus&0x10 != 0:
+		return ErrBufferOverflow
+	case errStatus&0x08 != 0:
+		pos, err := m.collisionPos()
+		if err != nil {
+			return err
+		}
+
+		return ErrCollision{BitPos: pos}
+	case errStatus&0x04 != 0:
+		return ErrCRC
+	case errStatus&0x02 != 0:
+		return ErrParity
+	case errStatus&0x01 != 0:
+		return ErrProtocol
+	}
+
+	return nil
 }
 
-/*
-	Not-implemented section
+// collisionPos returns the position of the first collided bit from the collision register,
+// starting at 1 with the first bit in the FIFO, or 0 if the position is not valid.
+func (m *MFRC522) collisionPos() (int, error) {
+	coll, err := m.ReadRegister(CollReg)
+	if err != nil {
+		return 0, err
+	}
+	if coll&0x20 != 0 {
+		return 0, nil
+	}
 
-	These functions are not needed for the basic functionality of the RFID reader,
-	so they were not implemented for the lab exercise, but might be in the future.
-*/
+	// A position of 0 means the 32nd bit
+	pos := int(coll & 0x1F)
+	if pos == 0 {
+		pos = 32
+	}
 
-// antiCollision2 performs the anti-collision procedure for tags with longer UIDs.
-func (m *MFRC522) antiCollision2() ([]byte, error) {
-	return nil, errors.New("not implemented")
+	return pos, nil
 }
@@ -0,0 +1,7 @@
+// Package linux connects the MFRC522 reader to a Linux host, such as a Raspberry Pi.
+// The registers are accessed through the kernel's spidev driver (/dev/spidevX.Y), and the
+// reset and interrupt pins through the GPIO character device (/dev/gpiochipN).
+//
+// The package is only built on Linux with regular Go, not with TinyGo, which uses the
+// machine package instead (see mfrc522.Init).
+package linux
@@ -0,0 +1,261 @@
+//go:build linux && !tinygo
+
+package linux
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Line flags of the GPIO character device (include/uapi/linux/gpio.h)
+const (
+	lineFlagInput       = 1 << 2
+	lineFlagOutput      = 1 << 3
+	lineFlagEdgeRising  = 1 << 4
+	lineFlagEdgeFalling = 1 << 5
+)
+
+// lineAttrIDOutputValues is the ID of the attribute that sets the level of an output line.
+const lineAttrIDOutputValues = 2
+
+// lineEventSize is the size of struct gpio_v2_line_event, which is read for every edge.
+const lineEventSize = 48
+
+// lineAttribute is struct gpio_v2_line_attribute.
+type lineAttribute struct {
+	id      uint32
+	padding uint32
+	value   uint64
+}
+
+// lineConfigAttribute is struct gpio_v2_line_config_attribute.
+type lineConfigAttribute struct {
+	attr lineAttribute
+	mask uint64
+}
+
+// lineConfig is struct gpio_v2_line_config.
+type lineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [10]lineConfigAttribute
+}
+
+// lineRequest is struct gpio_v2_line_request.
+type lineRequest struct {
+	offsets         [64]uint32
+	consumer        [32]byte
+	config          lineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+// lineValues is struct gpio_v2_line_values.
+type lineValues struct {
+	bits uint64
+	mask uint64
+}
+
+// GPIO ioctl requests (include/uapi/linux/gpio.h)
+var (
+	gpioV2GetLineIOCTL       = ioc(iocWrite|iocRead, 0xB4, 0x07, unsafe.Sizeof(lineRequest{}))
+	gpioV2LineSetConfigIOCTL = ioc(iocWrite|iocRead, 0xB4, 0x0D, unsafe.Sizeof(lineConfig{}))
+	gpioV2LineGetValuesIOCTL = ioc(iocWrite|iocRead, 0xB4, 0x0E, unsafe.Sizeof(lineValues{}))
+	gpioV2LineSetValuesIOCTL = ioc(iocWrite|iocRead, 0xB4, 0x0F, unsafe.Sizeof(lineValues{}))
+)
+
+// GPIOChip is a GPIO chip of the GPIO character device, whose lines can be used as the
+// reset and interrupt pins of the reader.
+type GPIOChip struct {
+	dev device
+
+	// fdDevice returns the device for the file descriptor of a requested line.
+	fdDevice func(fd int, name string) (device, error)
+}
+
+// OpenGPIOChip opens the GPIO chip at the path (e.g. /dev/gpiochip0).
+func OpenGPIOChip(path string) (*GPIOChip, error) {
+	dev, err := openDevice(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GPIOChip{dev: dev, fdDevice: fdDevice}, nil
+}
+
+// Line requests the line with the offset on the chip (on a Raspberry Pi, the BCM GPIO number),
+// and configures it as an input. The consumer is the label shown for the line by tools like gpioinfo.
+func (c *GPIOChip) Line(offset int, consumer string) (*GPIOLine, error) {
+	if offset < 0 || offset > 0xFFFF {
+		return nil, errors.New("invalid GPIO line offset")
+	}
+
+	req := lineRequest{numLines: 1}
+	req.offsets[0] = uint32(offset)
+	copy(req.consumer[:len(req.consumer)-1], consumer)
+	req.config.flags = lineFlagInput
+	if err := c.dev.ioctl(gpioV2GetLineIOCTL, unsafe.Pointer(&req)); err != nil {
+		return nil, err
+	}
+
+	dev, err := c.fdDevice(int(req.fd), consumer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GPIOLine{dev: dev, flags: lineFlagInput}, nil
+}
+
+// Close closes the chip. Lines that were requested stay usable until they are closed.
+func (c *GPIOChip) Close() error {
+	return c.dev.Close()
+}
+
+// GPIOLine is a line of a GPIO chip. It implements mfrc522.Pin.
+type GPIOLine struct {
+	dev device
+
+	mu sync.Mutex
+
+	// flags are the current flags of the line.
+	flags uint64
+
+	// done is closed when the goroutine that reads the edge events stopped,
+	// or is nil if there is none.
+	done chan struct{}
+}
+
+// Get configures the line as an input and returns its level.
+// It returns false if the level can't be read.
+func (l *GPIOLine) Get() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.flags&lineFlagInput == 0 {
+		if err := l.setConfig(lineFlagInput, false); err != nil {
+			return false
+		}
+	}
+
+	values := lineValues{mask: 1}
+	if err := l.dev.ioctl(gpioV2LineGetValuesIOCTL, unsafe.Pointer(&values)); err != nil {
+		return false
+	}
+
+	return values.bits&1 != 0
+}
+
+// Set configures the line as an output and drives it to the given level.
+// An interrupt that was set on the line is disabled.
+func (l *GPIOLine) Set(high bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.flags&lineFlagOutput == 0 {
+		_ = l.stopEvents()
+		_ = l.setConfig(lineFlagOutput, high)
+		return
+	}
+
+	values := lineValues{mask: 1}
+	if high {
+		values.bits = 1
+	}
+	_ = l.dev.ioctl(gpioV2LineSetValuesIOCTL, unsafe.Pointer(&values))
+}
+
+// SetInterrupt configures the line as an input and calls callback whenever its level changes.
+// The callback runs in a separate goroutine. A nil callback disables the interrupt.
+func (l *GPIOLine) SetInterrupt(callback func()) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.stopEvents(); err != nil {
+		return err
+	}
+	if callback == nil {
+		return l.setConfig(lineFlagInput, false)
+	}
+
+	if err := l.setConfig(lineFlagInput|lineFlagEdgeRising|lineFlagEdgeFalling, false); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	l.done = done
+	go l.readEvents(callback, done)
+
+	return nil
+}
+
+// Close stops the interrupt and releases the line.
+func (l *GPIOLine) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Closing the device also ends a pending read
+	err := l.dev.Close()
+	if l.done != nil {
+		<-l.done
+		l.done = nil
+	}
+
+	return err
+}
+
+// setConfig changes the flags of the line, and the level if it is an output.
+func (l *GPIOLine) setConfig(flags uint64, high bool) error {
+	config := lineConfig{flags: flags}
+	if flags&lineFlagOutput != 0 {
+		config.numAttrs = 1
+		config.attrs[0] = lineConfigAttribute{attr: lineAttribute{id: lineAttrIDOutputValues}, mask: 1}
+		if high {
+			config.attrs[0].attr.value = 1
+		}
+	}
+
+	if err := l.dev.ioctl(gpioV2LineSetConfigIOCTL, unsafe.Pointer(&config)); err != nil {
+		return err
+	}
+
+	l.flags = flags
+
+	return nil
+}
+
+// readEvents calls callback for every edge event of the line, until reading fails.
+func (l *GPIOLine) readEvents(callback func(), done chan struct{}) {
+	defer close(done)
+
+	buf := make([]byte, 16*lineEventSize)
+	for {
+		n, err := l.dev.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for range n / lineEventSize {
+			callback()
+		}
+	}
+}
+
+// stopEvents stops the goroutine that reads the edge events, by interrupting its pending read.
+func (l *GPIOLine) stopEvents() error {
+	if l.done == nil {
+		return nil
+	}
+
+	if err := l.dev.SetReadDeadline(time.Now()); err != nil {
+		return err
+	}
+	<-l.done
+	l.done = nil
+
+	return l.dev.SetReadDeadline(time.Time{})
+}
@@ -0,0 +1,221 @@
+//go:build linux && !tinygo
+
+package linux
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFakeLine requests the line with the offset from a fake chip, and returns the fake devices
+// of the chip and the line.
+func newFakeLine(t *testing.T, offset int, consumer string) (*GPIOLine, *fakeDevice, *fakeDevice) {
+	t.Helper()
+
+	chipDev := newFakeDevice(t)
+	chipDev.fd = 42
+	lineDev := newFakeDevice(t)
+	chip := &GPIOChip{dev: chipDev, fdDevice: func(fd int, name string) (device, error) {
+		if fd != 42 || name != consumer {
+			t.Errorf("fdDevice() called with %d and %q, want 42 and %q", fd, name, consumer)
+		}
+		return lineDev, nil
+	}}
+
+	line, err := chip.Line(offset, consumer)
+	if err != nil {
+		t.Fatalf("Line() error = %v", err)
+	}
+	t.Cleanup(func() { _ = line.Close() })
+
+	return line, chipDev, lineDev
+}
+
+// sendEvents writes n edge events to the line.
+func sendEvents(t *testing.T, dev *fakeDevice, n int) {
+	t.Helper()
+
+	if _, err := dev.events.Write(make([]byte, n*lineEventSize)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+}
+
+// waitForCalls waits until the callback was called n times.
+func waitForCalls(t *testing.T, calls *atomic.Int32, n int32) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("callback called %d times, want %d", calls.Load(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// stopped reports whether the channel is closed.
+func stopped(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestGPIOChipLine(t *testing.T) {
+	_, chipDev, lineDev := newFakeLine(t, 25, "mfrc522-rst")
+
+	request := chipDev.request
+	if request.numLines != 1 || request.offsets[0] != 25 || request.config.flags != lineFlagInput {
+		t.Errorf("Line() requested %d lines at offset %d with flags %#x, want 1 line at offset 25 with flags %#x",
+			request.numLines, request.offsets[0], request.config.flags, lineFlagInput)
+	}
+	if consumer, _, _ := bytes.Cut(request.consumer[:], []byte{0}); string(consumer) != "mfrc522-rst" {
+		t.Errorf("Line() consumer = %q, want %q", consumer, "mfrc522-rst")
+	}
+	if len(lineDev.requests) != 0 {
+		t.Errorf("Line() ran %d requests on the line, want 0", len(lineDev.requests))
+	}
+}
+
+func TestGPIOChipLineInvalid(t *testing.T) {
+	chip := &GPIOChip{dev: newFakeDevice(t), fdDevice: fdDevice}
+
+	for _, offset := range []int{-1, 0x10000} {
+		if _, err := chip.Line(offset, "mfrc522-rst"); err == nil {
+			t.Errorf("Line(%d) error = nil, want an error", offset)
+		}
+	}
+}
+
+func TestGPIOChipLineConsumer(t *testing.T) {
+	// The consumer is truncated to leave room for the terminating zero
+	consumer := string(bytes.Repeat([]byte{'a'}, 40))
+	_, chipDev, _ := newFakeLine(t, 25, consumer)
+
+	if chipDev.request.consumer[31] != 0 || string(chipDev.request.consumer[:31]) != consumer[:31] {
+		t.Errorf("Line() consumer = %q, want the first 31 bytes of %q", chipDev.request.consumer, consumer)
+	}
+}
+
+func TestGPIOLineSetGet(t *testing.T) {
+	line, _, dev := newFakeLine(t, 25, "mfrc522-rst")
+
+	// Set configures the line as an output with the level
+	line.Set(true)
+	config := dev.config
+	if config.flags != lineFlagOutput || config.numAttrs != 1 {
+		t.Fatalf("Set() config = flags %#x with %d attributes, want %#x with 1",
+			config.flags, config.numAttrs, lineFlagOutput)
+	}
+	if attr := config.attrs[0]; attr.attr.id != lineAttrIDOutputValues || attr.attr.value != 1 || attr.mask != 1 {
+		t.Errorf("Set() attribute = %+v, want the output value 1", attr)
+	}
+	if !dev.level {
+		t.Error("Set(true) left the line low")
+	}
+
+	// Once the line is an output, only the value is changed
+	requests := len(dev.requests)
+	line.Set(false)
+	if dev.level || len(dev.requests) != requests+1 || dev.requests[requests] != gpioV2LineSetValuesIOCTL {
+		t.Errorf("Set(false) = level %t with requests %#x, want low with the set values request",
+			dev.level, dev.requests[requests:])
+	}
+
+	// Get configures the line as an input
+	dev.level = true
+	if !line.Get() {
+		t.Error("Get() = false, want true")
+	}
+	if dev.config.flags != lineFlagInput || dev.config.numAttrs != 0 {
+		t.Errorf("Get() config = flags %#x with %d attributes, want %#x without attributes",
+			dev.config.flags, dev.config.numAttrs, lineFlagInput)
+	}
+
+	requests = len(dev.requests)
+	dev.level = false
+	if line.Get() {
+		t.Error("Get() = true, want false")
+	}
+	if len(dev.requests) != requests+1 || dev.requests[requests] != gpioV2LineGetValuesIOCTL {
+		t.Errorf("Get() on an input ran the requests %#x, want only the get values request", dev.requests[requests:])
+	}
+
+	// And Set configures it as an output again
+	line.Set(false)
+	if dev.config.flags != lineFlagOutput || dev.config.attrs[0].attr.value != 0 {
+		t.Errorf("Set() config = flags %#x with value %d, want %#x with 0",
+			dev.config.flags, dev.config.attrs[0].attr.value, lineFlagOutput)
+	}
+}
+
+func TestGPIOLineInterrupt(t *testing.T) {
+	line, _, dev := newFakeLine(t, 24, "mfrc522-irq")
+
+	var calls atomic.Int32
+	if err := line.SetInterrupt(func() { calls.Add(1) }); err != nil {
+		t.Fatalf("SetInterrupt() error = %v", err)
+	}
+	if want := uint64(lineFlagInput | lineFlagEdgeRising | lineFlagEdgeFalling); dev.config.flags != want {
+		t.Errorf("SetInterrupt() config flags = %#x, want %#x", dev.config.flags, want)
+	}
+
+	// Every event calls the callback
+	sendEvents(t, dev, 2)
+	waitForCalls(t, &calls, 2)
+
+	// A nil callback stops the goroutine and the edge detection
+	done := line.done
+	if err := line.SetInterrupt(nil); err != nil {
+		t.Fatalf("SetInterrupt(nil) error = %v", err)
+	}
+	if !stopped(done) || line.done != nil {
+		t.Error("SetInterrupt(nil) didn't stop reading the events")
+	}
+	if dev.config.flags != lineFlagInput {
+		t.Errorf("SetInterrupt(nil) config flags = %#x, want %#x", dev.config.flags, lineFlagInput)
+	}
+
+	// The read deadline was reset, so the interrupt can be enabled again
+	if err := line.SetInterrupt(func() { calls.Add(1) }); err != nil {
+		t.Fatalf("SetInterrupt() error = %v", err)
+	}
+	sendEvents(t, dev, 1)
+	waitForCalls(t, &calls, 3)
+
+	// Set makes the line an output, which has no events
+	done = line.done
+	line.Set(true)
+	if !stopped(done) || line.done != nil {
+		t.Error("Set() didn't stop reading the events")
+	}
+	if dev.config.flags != lineFlagOutput {
+		t.Errorf("Set() config flags = %#x, want %#x", dev.config.flags, lineFlagOutput)
+	}
+}
+
+func TestGPIOLineClose(t *testing.T) {
+	line, _, dev := newFakeLine(t, 24, "mfrc522-irq")
+
+	var calls atomic.Int32
+	if err := line.SetInterrupt(func() { calls.Add(1) }); err != nil {
+		t.Fatalf("SetInterrupt() error = %v", err)
+	}
+	sendEvents(t, dev, 1)
+	waitForCalls(t, &calls, 1)
+
+	done := line.done
+	if err := line.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !stopped(done) || line.done != nil {
+		t.Error("Close() didn't stop reading the events")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("callback called %d times, want 1", calls.Load())
+	}
+}
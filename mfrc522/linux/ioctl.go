@@ -0,0 +1,83 @@
+//go:build linux && !tinygo
+
+package linux
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// device is an open character device. It is implemented by file, and can be replaced
+// by a fake in tests.
+type device interface {
+	io.ReadWriteCloser
+
+	// SetReadDeadline interrupts a pending Read once t has passed.
+	SetReadDeadline(t time.Time) error
+
+	// ioctl runs the ioctl request with the argument on the device.
+	ioctl(req uintptr, arg unsafe.Pointer) error
+}
+
+// file is a device backed by a file descriptor.
+type file struct {
+	*os.File
+}
+
+// openDevice opens the character device at the path.
+func openDevice(path string) (device, error) {
+	fd, err := syscall.Open(path, syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	return file{os.NewFile(uintptr(fd), path)}, nil
+}
+
+// fdDevice returns the device for a file descriptor returned by the kernel.
+// The descriptor is made non-blocking, so the runtime poller handles it and a
+// pending Read can be interrupted with SetReadDeadline or Close.
+func fdDevice(fd int, name string) (device, error) {
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		_ = syscall.Close(fd)
+		return nil, os.NewSyscallError("setnonblock", err)
+	}
+
+	return file{os.NewFile(uintptr(fd), name)}, nil
+}
+
+// ioctl runs the ioctl request with the argument on the device.
+// The descriptor is accessed through SyscallConn, which keeps it non-blocking, unlike Fd.
+func (f file) ioctl(req uintptr, arg unsafe.Pointer) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return os.NewSyscallError("ioctl", errno)
+	}
+
+	return nil
+}
+
+// ioctl directions
+const (
+	iocWrite = 1
+	iocRead  = 2
+)
+
+// ioc encodes an ioctl request number, like the _IOC macro of the kernel.
+// This is the generic encoding, used by x86 and ARM among others.
+func ioc(dir, typ, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | typ<<8 | nr
+}
@@ -0,0 +1,181 @@
+//go:build linux && !tinygo
+
+package linux
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// fakeDevice is a device that handles the ioctl requests of the spidev and GPIO drivers.
+// Reads come from a pipe, so edge events can be written to events.
+type fakeDevice struct {
+	*os.File
+
+	// events is the write end of the pipe that is read by Read.
+	events *os.File
+
+	mu sync.Mutex
+
+	// requests are the ioctl requests in the order they were run.
+	requests []uintptr
+
+	// err is returned by the ioctl requests, if set.
+	err error
+
+	// mode, bits and speed are the SPI settings.
+	mode, bits uint8
+	speed      uint32
+
+	// transfers are the SPI transfers, and mosi the data that was sent with them.
+	transfers []spiTransfer
+	mosi      [][]byte
+
+	// miso is the data that is received by the next SPI transfer.
+	miso []byte
+
+	// request is the last line request, and fd the descriptor returned for it.
+	request lineRequest
+	fd      int32
+
+	// config is the last configuration of the line, and level its level.
+	config lineConfig
+	level  bool
+}
+
+// newFakeDevice creates a fake device, which is closed at the end of the test.
+func newFakeDevice(t *testing.T) *fakeDevice {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = r.Close()
+		_ = w.Close()
+	})
+
+	return &fakeDevice{File: r, events: w}
+}
+
+// ioctl handles the request like the driver would.
+func (d *fakeDevice) ioctl(req uintptr, arg unsafe.Pointer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.requests = append(d.requests, req)
+	if d.err != nil {
+		return d.err
+	}
+
+	switch req {
+	case spiIOCWrMode:
+		d.mode = *(*uint8)(arg)
+	case spiIOCWrBitsPerWord:
+		d.bits = *(*uint8)(arg)
+	case spiIOCWrMaxSpeedHz:
+		d.speed = *(*uint32)(arg)
+	case spiIOCMessage1:
+		return d.transfer(*(*spiTransfer)(arg))
+	case gpioV2GetLineIOCTL:
+		request := (*lineRequest)(arg)
+		d.request = *request
+		request.fd = d.fd
+	case gpioV2LineSetConfigIOCTL:
+		d.config = *(*lineConfig)(arg)
+		if d.config.flags&lineFlagOutput != 0 {
+			d.level = d.config.attrs[0].attr.value&1 != 0
+		}
+	case gpioV2LineGetValuesIOCTL:
+		values := (*lineValues)(arg)
+		values.bits = 0
+		if d.level {
+			values.bits = values.mask & 1
+		}
+	case gpioV2LineSetValuesIOCTL:
+		values := (*lineValues)(arg)
+		d.level = values.bits&values.mask&1 != 0
+	default:
+		return syscall.ENOTTY
+	}
+
+	return nil
+}
+
+// transfer copies the data from and to the buffers of the transfer. The buffers are only
+// known by their addresses, so, like in the driver, the kernel copies them through a pipe.
+func (d *fakeDevice) transfer(transfer spiTransfer) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.Close()
+		_ = w.Close()
+	}()
+
+	n := uintptr(transfer.len)
+	if _, _, errno := syscall.Syscall(syscall.SYS_WRITE, w.Fd(), uintptr(transfer.txBuf), n); errno != 0 {
+		return errno
+	}
+	mosi := make([]byte, n)
+	if _, err := io.ReadFull(r, mosi); err != nil {
+		return err
+	}
+
+	miso := make([]byte, n)
+	copy(miso, d.miso)
+	if _, err := w.Write(miso); err != nil {
+		return err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_READ, r.Fd(), uintptr(transfer.rxBuf), n); errno != 0 {
+		return errno
+	}
+
+	d.transfers = append(d.transfers, transfer)
+	d.mosi = append(d.mosi, mosi)
+
+	return nil
+}
+
+func TestIoctlRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      uintptr
+		want     uintptr
+		size     uintptr
+		wantSize uintptr
+	}{
+		{"SPI_IOC_MESSAGE(1)", spiIOCMessage1, 0x40206B00, unsafe.Sizeof(spiTransfer{}), 32},
+		{"SPI_IOC_WR_MODE", spiIOCWrMode, 0x40016B01, 1, 1},
+		{"SPI_IOC_WR_BITS_PER_WORD", spiIOCWrBitsPerWord, 0x40016B03, 1, 1},
+		{"SPI_IOC_WR_MAX_SPEED_HZ", spiIOCWrMaxSpeedHz, 0x40046B04, 4, 4},
+		{"GPIO_V2_GET_LINE_IOCTL", gpioV2GetLineIOCTL, 0xC250B407, unsafe.Sizeof(lineRequest{}), 592},
+		{"GPIO_V2_LINE_SET_CONFIG_IOCTL", gpioV2LineSetConfigIOCTL, 0xC110B40D, unsafe.Sizeof(lineConfig{}), 272},
+		{"GPIO_V2_LINE_GET_VALUES_IOCTL", gpioV2LineGetValuesIOCTL, 0xC010B40E, unsafe.Sizeof(lineValues{}), 16},
+		{"GPIO_V2_LINE_SET_VALUES_IOCTL", gpioV2LineSetValuesIOCTL, 0xC010B40F, unsafe.Sizeof(lineValues{}), 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.req != tt.want {
+				t.Errorf("request = %#x, want %#x", tt.req, tt.want)
+			}
+			if tt.size != tt.wantSize {
+				t.Errorf("size = %d, want %d", tt.size, tt.wantSize)
+			}
+		})
+	}
+
+	if offset := unsafe.Offsetof(lineRequest{}.fd); offset != 588 {
+		t.Errorf("offset of lineRequest.fd = %d, want 588", offset)
+	}
+	if offset := unsafe.Offsetof(lineConfig{}.attrs); offset != 32 {
+		t.Errorf("offset of lineConfig.attrs = %d, want 32", offset)
+	}
+}
@@ -0,0 +1,104 @@
+//go:build linux && !tinygo
+
+package linux
+
+import (
+	"errors"
+	"time"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+)
+
+// NoLine is passed to Init instead of a line offset, if the pin is not connected.
+const NoLine = -1
+
+// Reader is an MFRC522 reader connected to a Linux host. It has the same methods as
+// mfrc522.MFRC522, and Close releases the devices it uses.
+type Reader struct {
+	*mfrc522.MFRC522
+
+	spi   *SPIDev
+	lines []*GPIOLine
+}
+
+// Init initializes the MFRC522 reader connected to the spidev device at spiPath (e.g. /dev/spidev0.0).
+// The reset and interrupt pins are lines of the GPIO chip at chipPath (e.g. /dev/gpiochip0), and
+// can be NoLine if they are not connected. Without an interrupt pin, the reader's interrupt register
+// is polled instead. The options (e.g. mfrc522.WithAntennaGain) are passed on to mfrc522.New.
+func Init(spiPath, chipPath string, rstLine, irqLine int, irqTimeout time.Duration,
+	opts ...mfrc522.Option,
+) (*Reader, error) {
+	spi, err := OpenSPI(spiPath, DefaultSPISpeed)
+	if err != nil {
+		return nil, errors.New("failed to open SPI device: " + err.Error())
+	}
+
+	r := &Reader{spi: spi}
+	rst, irq, err := r.openLines(chipPath, rstLine, irqLine)
+	if err != nil {
+		_ = r.closeDevices()
+		return nil, errors.New("failed to request GPIO line: " + err.Error())
+	}
+
+	m, err := mfrc522.New(mfrc522.NewSPIBus(spi, nil), rst, irq, irqTimeout, opts...)
+	if err != nil {
+		_ = r.closeDevices()
+		return nil, err
+	}
+	r.MFRC522 = m
+
+	return r, nil
+}
+
+// openLines requests the reset and interrupt lines, and returns them as pins,
+// which are nil if the line is not connected.
+func (r *Reader) openLines(chipPath string, rstLine, irqLine int) (rst, irq mfrc522.Pin, err error) {
+	if rstLine == NoLine && irqLine == NoLine {
+		return nil, nil, nil
+	}
+
+	chip, err := OpenGPIOChip(chipPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = chip.Close() }()
+
+	if rstLine != NoLine {
+		line, err := chip.Line(rstLine, "mfrc522-rst")
+		if err != nil {
+			return nil, nil, err
+		}
+		r.lines = append(r.lines, line)
+		rst = line
+	}
+	if irqLine != NoLine {
+		line, err := chip.Line(irqLine, "mfrc522-irq")
+		if err != nil {
+			return nil, nil, err
+		}
+		r.lines = append(r.lines, line)
+		irq = line
+	}
+
+	return rst, irq, nil
+}
+
+// Close turns off the antenna and disables the interrupt like Exit,
+// and releases the SPI device and the GPIO lines.
+func (r *Reader) Close() error {
+	r.Exit()
+
+	return r.closeDevices()
+}
+
+// closeDevices closes the SPI device and the GPIO lines.
+func (r *Reader) closeDevices() error {
+	err := r.spi.Close()
+	for _, line := range r.lines {
+		if lineErr := line.Close(); err == nil {
+			err = lineErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,128 @@
+//go:build linux && !tinygo
+
+package linux
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Terminal ioctl requests and flags that are missing in the syscall package
+// (include/uapi/asm-generic/ioctls.h and termbits.h)
+const (
+	tcsetsw = syscall.TCSETS + 1
+	cbaud   = 0x100F
+)
+
+// serialTimeout is the maximum time Serial waits for a received byte.
+const serialTimeout = 100 * time.Millisecond
+
+// baudRates are the terminal speeds for the baud rates the reader supports.
+// The other rates of the reader (7200, 14400, 128000 and 1228800) have no terminal speed.
+var baudRates = map[uint32]uint32{
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+	230400: syscall.B230400,
+	460800: syscall.B460800,
+	921600: syscall.B921600,
+}
+
+// Serial is a serial port (e.g. /dev/ttyS0, /dev/ttyUSB0 or a pseudo terminal).
+// It implements mfrc522.UART, so it can be passed to mfrc522.NewUARTBus.
+type Serial struct {
+	dev device
+}
+
+// OpenSerial opens the serial port at the path, and configures it for the MFRC522 reader:
+// raw mode with 8 data bits, no parity, one stop bit and the baud rate.
+func OpenSerial(path string, baud uint32) (*Serial, error) {
+	fd, err := syscall.Open(path, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	dev, err := fdDevice(fd, path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Serial{dev: dev}
+	if err := s.configure(baud); err != nil {
+		_ = dev.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Write sends the data.
+func (s *Serial) Write(data []byte) (int, error) {
+	return s.dev.Write(data)
+}
+
+// ReadByte waits for the next received byte, and returns an error if none arrived in time.
+func (s *Serial) ReadByte() (byte, error) {
+	if err := s.dev.SetReadDeadline(time.Now().Add(serialTimeout)); err != nil {
+		return 0, err
+	}
+
+	var buf [1]byte
+	if _, err := s.dev.Read(buf[:]); err != nil {
+		return 0, err
+	}
+
+	return buf[0], nil
+}
+
+// SetBaudRate changes the baud rate of the port, after the pending output was sent.
+func (s *Serial) SetBaudRate(baud uint32) error {
+	speed, ok := baudRates[baud]
+	if !ok {
+		return errors.New("unsupported baud rate " + strconv.Itoa(int(baud)))
+	}
+
+	var t syscall.Termios
+	if err := s.dev.ioctl(syscall.TCGETS, unsafe.Pointer(&t)); err != nil {
+		return err
+	}
+
+	t.Cflag = t.Cflag&^cbaud | speed
+	t.Ispeed = speed
+	t.Ospeed = speed
+
+	return s.dev.ioctl(tcsetsw, unsafe.Pointer(&t))
+}
+
+// Close closes the port.
+func (s *Serial) Close() error {
+	return s.dev.Close()
+}
+
+// configure sets the port to raw mode with 8N1 and the baud rate.
+func (s *Serial) configure(baud uint32) error {
+	var t syscall.Termios
+	if err := s.dev.ioctl(syscall.TCGETS, unsafe.Pointer(&t)); err != nil {
+		return err
+	}
+
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON | syscall.IXOFF
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB | syscall.CSTOPB
+	t.Cflag |= syscall.CS8 | syscall.CREAD | syscall.CLOCAL
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+	if err := s.dev.ioctl(syscall.TCSETS, unsafe.Pointer(&t)); err != nil {
+		return err
+	}
+
+	return s.SetBaudRate(baud)
+}
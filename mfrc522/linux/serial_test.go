@@ -0,0 +1,259 @@
+//go:build linux && !tinygo
+
+package linux
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+	"github.com/msthtrifork/gorfid/mfrc522/sim"
+)
+
+// serialSpeeds are the baud rates for the SerialSpeedReg values that are used in the tests.
+var serialSpeeds = map[byte]uint32{
+	0xEB: 9600,
+	0xCB: 19200,
+	0x7A: 115200,
+	0x1C: 921600,
+}
+
+// serialLine connects a simulated chip to the master of a pseudo terminal.
+// Like on a real serial line, bytes are lost while the baud rates of the chip and the terminal differ.
+type serialLine struct {
+	master *os.File
+	chip   *sim.Chip
+}
+
+// Read returns the bytes sent by the terminal that the chip can receive.
+func (l serialLine) Read(p []byte) (int, error) {
+	for {
+		n, err := l.master.Read(p)
+		if err != nil || l.synced() {
+			return n, err
+		}
+	}
+}
+
+// Write sends the data to the terminal, unless it uses another baud rate.
+func (l serialLine) Write(p []byte) (int, error) {
+	if !l.synced() {
+		return len(p), nil
+	}
+
+	return l.master.Write(p)
+}
+
+// synced reports whether the chip and the terminal use the same baud rate.
+func (l serialLine) synced() bool {
+	val, err := l.chip.ReadRegisterBytes(mfrc522.SerialSpeedReg, 1)
+	if err != nil {
+		return false
+	}
+	speed, err := terminalSpeed(l.master)
+	if err != nil {
+		return false
+	}
+
+	return baudRates[serialSpeeds[val[0]]] == speed
+}
+
+// terminalSpeed returns the speed of the pseudo terminal. The settings are shared by the
+// master and the slave.
+func terminalSpeed(master *os.File) (uint32, error) {
+	var t syscall.Termios
+	if err := (file{master}).ioctl(syscall.TCGETS, unsafe.Pointer(&t)); err != nil {
+		return 0, err
+	}
+
+	return t.Cflag & cbaud, nil
+}
+
+// openPTY opens a pseudo terminal, and returns its master and the path of its slave.
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("no pseudo terminals: %v", err)
+	}
+	t.Cleanup(func() { _ = master.Close() })
+
+	var unlock int32
+	if err := (file{master}).ioctl(syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		t.Fatalf("unlocking the pseudo terminal failed: %v", err)
+	}
+	var n uint32
+	if err := (file{master}).ioctl(syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		t.Fatalf("getting the pseudo terminal number failed: %v", err)
+	}
+
+	return master, "/dev/pts/" + strconv.Itoa(int(n))
+}
+
+// newSerialBus creates a UART bus for a simulated chip that is served on a pseudo terminal.
+func newSerialBus(t *testing.T) (*mfrc522.UARTBus, *sim.Chip, *os.File) {
+	t.Helper()
+
+	master, path := openPTY(t)
+	c := sim.NewChip()
+	served := make(chan error, 1)
+	go func() { served <- c.ServeUART(serialLine{master: master, chip: c}) }()
+
+	s, err := OpenSerial(path, mfrc522.DefaultBaudRate)
+	if err != nil {
+		t.Fatalf("OpenSerial() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = s.Close()
+		_ = master.Close()
+		<-served
+	})
+
+	return mfrc522.NewUARTBus(s), c, master
+}
+
+// checkBaudRate checks that the bus, the chip and the terminal use the baud rate.
+func checkBaudRate(t *testing.T, bus *mfrc522.UARTBus, c *sim.Chip, master *os.File, baud uint32) {
+	t.Helper()
+
+	if bus.BaudRate() != baud {
+		t.Errorf("BaudRate() = %d, want %d", bus.BaudRate(), baud)
+	}
+	val, err := c.ReadRegisterBytes(mfrc522.SerialSpeedReg, 1)
+	if err != nil {
+		t.Fatalf("ReadRegisterBytes() error = %v", err)
+	}
+	if serialSpeeds[val[0]] != baud {
+		t.Errorf("SerialSpeedReg = %02x, want the value for %d", val[0], baud)
+	}
+	if speed, err := terminalSpeed(master); err != nil || speed != baudRates[baud] {
+		t.Errorf("terminal speed = %#o, %v, want %#o", speed, err, baudRates[baud])
+	}
+}
+
+// checkRegisters checks that the registers can be read and written over the bus.
+func checkRegisters(t *testing.T, bus *mfrc522.UARTBus) {
+	t.Helper()
+
+	version, err := bus.ReadRegisterBytes(mfrc522.VersionReg, 1)
+	if err != nil {
+		t.Fatalf("ReadRegisterBytes() error = %v", err)
+	}
+	if version[0] != 0x92 {
+		t.Errorf("VersionReg = %02x, want 92", version[0])
+	}
+
+	if err := bus.WriteRegisterBytes(mfrc522.FIFOLevelReg, []byte{0x80}); err != nil {
+		t.Fatalf("WriteRegisterBytes() error = %v", err)
+	}
+	data := []byte{0x01, 0x02, 0x03}
+	if err := bus.WriteRegisterBytes(mfrc522.FIFODataReg, data); err != nil {
+		t.Fatalf("WriteRegisterBytes() error = %v", err)
+	}
+	level, err := bus.ReadRegisterBytes(mfrc522.FIFOLevelReg, 1)
+	if err != nil {
+		t.Fatalf("ReadRegisterBytes() error = %v", err)
+	}
+	if level[0] != byte(len(data)) {
+		t.Fatalf("FIFOLevelReg = %d, want %d", level[0], len(data))
+	}
+	got, err := bus.ReadRegisterBytes(mfrc522.FIFODataReg, len(data))
+	if err != nil {
+		t.Fatalf("ReadRegisterBytes() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("FIFODataReg = % x, want % x", got, data)
+	}
+}
+
+func TestSerialRegisters(t *testing.T) {
+	bus, c, master := newSerialBus(t)
+
+	checkBaudRate(t, bus, c, master, mfrc522.DefaultBaudRate)
+	checkRegisters(t, bus)
+}
+
+func TestSerialSetBaudRate(t *testing.T) {
+	for _, baud := range []uint32{19200, 115200, 921600} {
+		t.Run(strconv.Itoa(int(baud)), func(t *testing.T) {
+			bus, c, master := newSerialBus(t)
+
+			// The register is read back with the new rate, which only works if both sides switched
+			if err := bus.SetBaudRate(baud); err != nil {
+				t.Fatalf("SetBaudRate() error = %v", err)
+			}
+			checkBaudRate(t, bus, c, master, baud)
+			checkRegisters(t, bus)
+
+			// And back to the default rate
+			if err := bus.SetBaudRate(mfrc522.DefaultBaudRate); err != nil {
+				t.Fatalf("SetBaudRate() error = %v", err)
+			}
+			checkBaudRate(t, bus, c, master, mfrc522.DefaultBaudRate)
+			checkRegisters(t, bus)
+		})
+	}
+}
+
+func TestSerialSetBaudRateUnsupported(t *testing.T) {
+	bus, c, master := newSerialBus(t)
+
+	// The reader supports these rates, but the terminal doesn't, so the reader is not switched
+	for _, baud := range []uint32{7200, 14400, 128000, 1228800, 4800} {
+		if err := bus.SetBaudRate(baud); err == nil {
+			t.Errorf("SetBaudRate(%d) error = nil, want an error", baud)
+		}
+	}
+
+	checkBaudRate(t, bus, c, master, mfrc522.DefaultBaudRate)
+	checkRegisters(t, bus)
+}
+
+func TestSerialSoftReset(t *testing.T) {
+	bus, c, master := newSerialBus(t)
+	if err := bus.SetBaudRate(115200); err != nil {
+		t.Fatalf("SetBaudRate() error = %v", err)
+	}
+
+	// The soft reset sets the reader back to the default rate, and the bus negotiates the rate again
+	m, err := mfrc522.New(bus, nil, c.IRQ(), time.Second)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := m.Reset(); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	checkBaudRate(t, bus, c, master, 115200)
+	checkRegisters(t, bus)
+
+	// New turned on the antenna, which is off again after the reset
+	txControl, err := m.ReadRegister(mfrc522.TxControlReg)
+	if err != nil {
+		t.Fatalf("ReadRegister() error = %v", err)
+	}
+	if txControl != 0x80 {
+		t.Errorf("TxControlReg after Reset() = %02x, want 80", txControl)
+	}
+
+	card, err := sim.NewClassic(sim.Classic1K, []byte{0xDE, 0xAD, 0xBE, 0xEF})
+	if err != nil {
+		t.Fatalf("NewClassic() error = %v", err)
+	}
+	c.Add(card)
+	if m, err = mfrc522.New(bus, nil, c.IRQ(), time.Second); err != nil {
+		t.Fatalf("New() after Reset() error = %v", err)
+	}
+	info, err := m.ReadCard()
+	if err != nil {
+		t.Fatalf("ReadCard() error = %v", err)
+	}
+	if !bytes.Equal(info.UUID, card.UID()) {
+		t.Errorf("ReadCard().UUID = % x, want % x", info.UUID, card.UID())
+	}
+}
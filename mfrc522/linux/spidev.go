@@ -0,0 +1,121 @@
+//go:build linux && !tinygo
+
+package linux
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// spiTransfer is struct spi_ioc_transfer of the spidev driver.
+type spiTransfer struct {
+	txBuf       uint64
+	rxBuf       uint64
+	len         uint32
+	speedHz     uint32
+	delayUsecs  uint16
+	bitsPerWord uint8
+	csChange    uint8
+	txNbits     uint8
+	rxNbits     uint8
+	wordDelay   uint8
+	pad         uint8
+}
+
+// spidev ioctl requests (include/uapi/linux/spi/spidev.h)
+var (
+	spiIOCMessage1      = ioc(iocWrite, 'k', 0, unsafe.Sizeof(spiTransfer{}))
+	spiIOCWrMode        = ioc(iocWrite, 'k', 1, 1)
+	spiIOCWrBitsPerWord = ioc(iocWrite, 'k', 3, 1)
+	spiIOCWrMaxSpeedHz  = ioc(iocWrite, 'k', 4, 4)
+)
+
+// DefaultSPISpeed is the SPI clock used by Init, the same as on the microcontrollers.
+const DefaultSPISpeed = 1000000
+
+// SPIDev is an SPI device of the spidev driver. It implements mfrc522.SPI, so it can be
+// passed to mfrc522.NewSPIBus. Chip-select is handled by the driver.
+type SPIDev struct {
+	dev device
+
+	// speed is the SPI clock in Hz.
+	speed uint32
+}
+
+// OpenSPI opens the spidev device at the path (e.g. /dev/spidev0.0) and configures it for the
+// MFRC522 reader: SPI mode 0 with 8 bits per word, and the clock speed in Hz.
+func OpenSPI(path string, speed uint32) (*SPIDev, error) {
+	dev, err := openDevice(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := newSPIDev(dev, speed)
+	if err != nil {
+		_ = dev.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// newSPIDev configures the device for the MFRC522 reader.
+func newSPIDev(dev device, speed uint32) (*SPIDev, error) {
+	if speed == 0 {
+		speed = DefaultSPISpeed
+	}
+
+	mode := uint8(0)
+	if err := dev.ioctl(spiIOCWrMode, unsafe.Pointer(&mode)); err != nil {
+		return nil, err
+	}
+	bits := uint8(8)
+	if err := dev.ioctl(spiIOCWrBitsPerWord, unsafe.Pointer(&bits)); err != nil {
+		return nil, err
+	}
+	if err := dev.ioctl(spiIOCWrMaxSpeedHz, unsafe.Pointer(&speed)); err != nil {
+		return nil, err
+	}
+
+	return &SPIDev{dev: dev, speed: speed}, nil
+}
+
+// Tx sends w and receives into r in a single transfer, with chip-select held active.
+// Either of them can be nil, and the shorter one is padded with zeros.
+func (s *SPIDev) Tx(w, r []byte) error {
+	n := max(len(w), len(r))
+	if n == 0 {
+		return nil
+	}
+
+	// The kernel only gets the addresses of the buffers, which the garbage collector doesn't
+	// know about, so they are pinned to the heap until the transfer finished
+	tx := make([]byte, n)
+	rx := make([]byte, n)
+	copy(tx, w)
+
+	var pinner runtime.Pinner
+	defer pinner.Unpin()
+	pinner.Pin(&tx[0])
+	pinner.Pin(&rx[0])
+
+	transfer := spiTransfer{
+		txBuf:       uint64(uintptr(unsafe.Pointer(&tx[0]))),
+		rxBuf:       uint64(uintptr(unsafe.Pointer(&rx[0]))),
+		len:         uint32(n),
+		speedHz:     s.speed,
+		bitsPerWord: 8,
+	}
+	if err := s.dev.ioctl(spiIOCMessage1, unsafe.Pointer(&transfer)); err != nil {
+		return err
+	}
+
+	copy(r, rx)
+
+	return nil
+}
+
+// Close closes the device.
+func (s *SPIDev) Close() error {
+	return s.dev.Close()
+}
@@ -0,0 +1,139 @@
+//go:build linux && !tinygo
+
+package linux
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+)
+
+// newFakeSPI creates an SPI device backed by a fake device.
+func newFakeSPI(t *testing.T, speed uint32) (*SPIDev, *fakeDevice) {
+	t.Helper()
+
+	dev := newFakeDevice(t)
+	s, err := newSPIDev(dev, speed)
+	if err != nil {
+		t.Fatalf("newSPIDev() error = %v", err)
+	}
+
+	return s, dev
+}
+
+func TestNewSPIDev(t *testing.T) {
+	tests := []struct {
+		name  string
+		speed uint32
+		want  uint32
+	}{
+		{"default speed", 0, DefaultSPISpeed},
+		{"speed", 4000000, 4000000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, dev := newFakeSPI(t, tt.speed)
+			if dev.mode != 0 || dev.bits != 8 || dev.speed != tt.want {
+				t.Errorf("newSPIDev() = mode %d, %d bits, %d Hz, want mode 0, 8 bits, %d Hz",
+					dev.mode, dev.bits, dev.speed, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSPIDevError(t *testing.T) {
+	dev := newFakeDevice(t)
+	dev.err = syscall.ENOTTY
+
+	if _, err := newSPIDev(dev, 0); !errors.Is(err, syscall.ENOTTY) {
+		t.Errorf("newSPIDev() error = %v, want %v", err, syscall.ENOTTY)
+	}
+}
+
+func TestSPIDevTx(t *testing.T) {
+	tests := []struct {
+		name     string
+		w        []byte
+		readLen  int
+		miso     []byte
+		wantMOSI []byte
+		wantR    []byte
+	}{
+		{"read", []byte{0xEE, 0x00}, 2, []byte{0x00, 0x92}, []byte{0xEE, 0x00}, []byte{0x00, 0x92}},
+		{"write", []byte{0x02, 0x0F}, 0, []byte{0x00, 0x00}, []byte{0x02, 0x0F}, []byte{}},
+		{"longer read", []byte{0x80}, 3, []byte{0x00, 0x12, 0x34}, []byte{0x80, 0x00, 0x00}, []byte{0x00, 0x12, 0x34}},
+		{"shorter read", []byte{0x80, 0x80, 0x00}, 1, []byte{0xAA, 0x12, 0x34}, []byte{0x80, 0x80, 0x00}, []byte{0xAA}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dev := newFakeSPI(t, 0)
+			dev.miso = tt.miso
+
+			r := make([]byte, tt.readLen)
+			if err := s.Tx(tt.w, r); err != nil {
+				t.Fatalf("Tx() error = %v", err)
+			}
+			if !bytes.Equal(r, tt.wantR) {
+				t.Errorf("Tx() received % x, want % x", r, tt.wantR)
+			}
+
+			if len(dev.transfers) != 1 {
+				t.Fatalf("Tx() ran %d transfers, want 1", len(dev.transfers))
+			}
+			transfer := dev.transfers[0]
+			if !bytes.Equal(dev.mosi[0], tt.wantMOSI) {
+				t.Errorf("Tx() sent % x, want % x", dev.mosi[0], tt.wantMOSI)
+			}
+			if int(transfer.len) != len(tt.wantMOSI) || transfer.speedHz != DefaultSPISpeed || transfer.bitsPerWord != 8 {
+				t.Errorf("transfer = %d bytes at %d Hz with %d bits, want %d bytes at %d Hz with 8 bits",
+					transfer.len, transfer.speedHz, transfer.bitsPerWord, len(tt.wantMOSI), DefaultSPISpeed)
+			}
+			if transfer.txBuf == 0 || transfer.rxBuf == 0 || transfer.txBuf == transfer.rxBuf {
+				t.Errorf("transfer buffers = %#x and %#x, want two separate buffers", transfer.txBuf, transfer.rxBuf)
+			}
+		})
+	}
+}
+
+func TestSPIDevTxEmpty(t *testing.T) {
+	s, dev := newFakeSPI(t, 0)
+
+	if err := s.Tx(nil, nil); err != nil {
+		t.Fatalf("Tx() error = %v", err)
+	}
+	if len(dev.transfers) != 0 {
+		t.Errorf("Tx() without data ran %d transfers, want 0", len(dev.transfers))
+	}
+}
+
+func TestSPIDevTxError(t *testing.T) {
+	s, dev := newFakeSPI(t, 0)
+	dev.err = syscall.EIO
+
+	if err := s.Tx([]byte{0x80}, make([]byte, 1)); !errors.Is(err, syscall.EIO) {
+		t.Errorf("Tx() error = %v, want %v", err, syscall.EIO)
+	}
+}
+
+func TestSPIDevBus(t *testing.T) {
+	s, dev := newFakeSPI(t, 0)
+	bus := mfrc522.NewSPIBus(s, nil)
+
+	// The register address is sent in the first byte, and the value is received in the second
+	dev.miso = []byte{0x00, 0x92}
+	val, err := bus.ReadRegisterBytes(mfrc522.VersionReg, 1)
+	if err != nil {
+		t.Fatalf("ReadRegisterBytes() error = %v", err)
+	}
+	if !bytes.Equal(val, []byte{0x92}) {
+		t.Errorf("ReadRegisterBytes() = % x, want 92", val)
+	}
+	if want := []byte{0x80 | byte(mfrc522.VersionReg)<<1, 0x00}; !bytes.Equal(dev.mosi[0], want) {
+		t.Errorf("ReadRegisterBytes() sent % x, want % x", dev.mosi[0], want)
+	}
+}
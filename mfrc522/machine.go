@@ -0,0 +1,112 @@
+//go:build tinygo
+
+package mfrc522
+
+import (
+	"errors"
+	"machine"
+	"runtime"
+	"time"
+)
+
+// Init initializes the MFRC522 reader connected to the board's default SPI interface.
+// The reset and interrupt pins can be machine.NoPin if they are not connected.
+// Without an interrupt pin, the reader's interrupt register is polled instead.
+// The options (e.g. WithAntennaGain) are passed on to New.
+func Init(rstPin, irqPin machine.Pin, irqTimeout time.Duration, opts ...Option) (*MFRC522, error) {
+	if err := machine.SPI0.Configure(machine.SPIConfig{Frequency: 1000000}); err != nil {
+		return nil, errors.New("failed to configure SPI: " + err.Error())
+	}
+
+	return New(NewSPIBus(machine.SPI0, nil), optionalPin(rstPin), optionalPin(irqPin), irqTimeout, opts...)
+}
+
+// InitI2C initializes the MFRC522 reader connected to the board's default I2C interface,
+// with the 7-bit address set by its pins (see I2CAddress).
+// The pins and options are the same as for Init.
+func InitI2C(addr uint16, rstPin, irqPin machine.Pin, irqTimeout time.Duration, opts ...Option) (*MFRC522, error) {
+	if err := machine.I2C0.Configure(machine.I2CConfig{Frequency: 400000}); err != nil {
+		return nil, errors.New("failed to configure I2C: " + err.Error())
+	}
+
+	return New(NewI2CBus(machine.I2C0, addr), optionalPin(rstPin), optionalPin(irqPin), irqTimeout, opts...)
+}
+
+// optionalPin returns the pin as a Pin, or nil if it is machine.NoPin.
+func optionalPin(pin machine.Pin) Pin {
+	if pin == machine.NoPin {
+		return nil
+	}
+
+	return MachinePin(pin)
+}
+
+// uartTimeout is the maximum time MachineUART waits for a received byte.
+const uartTimeout = 100 * time.Millisecond
+
+// MachineUART is a TinyGo machine.UART that implements the UART interface.
+type MachineUART struct {
+	uart *machine.UART
+}
+
+// NewMachineUART returns the UART as a UART for NewUARTBus. It must already be configured
+// with DefaultBaudRate, which the reader uses after a reset.
+func NewMachineUART(uart *machine.UART) *MachineUART {
+	return &MachineUART{uart: uart}
+}
+
+// Write sends the data.
+func (u *MachineUART) Write(data []byte) (int, error) {
+	return u.uart.Write(data)
+}
+
+// ReadByte waits for the next received byte, and returns ErrTimeout if none arrived in time.
+func (u *MachineUART) ReadByte() (byte, error) {
+	deadline := time.Now().Add(uartTimeout)
+	for u.uart.Buffered() == 0 {
+		if time.Now().After(deadline) {
+			return 0, wrap("waiting for the reader's UART", ErrTimeout)
+		}
+
+		runtime.Gosched()
+	}
+
+	return u.uart.ReadByte()
+}
+
+// SetBaudRate changes the baud rate of the UART.
+func (u *MachineUART) SetBaudRate(baud uint32) error {
+	u.uart.SetBaudRate(baud)
+
+	return nil
+}
+
+// MachinePin is a TinyGo machine.Pin that implements the Pin interface.
+type MachinePin machine.Pin
+
+// Get configures the pin as an input and returns its level.
+func (p MachinePin) Get() bool {
+	pin := machine.Pin(p)
+	pin.Configure(machine.PinConfig{Mode: machine.PinInput})
+
+	return pin.Get()
+}
+
+// Set configures the pin as an output and drives it to the given level.
+func (p MachinePin) Set(high bool) {
+	pin := machine.Pin(p)
+	pin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	pin.Set(high)
+}
+
+// SetInterrupt calls callback whenever the level of the pin changes.
+func (p MachinePin) SetInterrupt(callback func()) error {
+	pin := machine.Pin(p)
+	if callback == nil {
+		return pin.SetInterrupt(0, nil)
+	}
+
+	pin.Configure(machine.PinConfig{Mode: machine.PinInput})
+
+	return pin.SetInterrupt(machine.PinToggle, func(machine.Pin) { callback() })
+}
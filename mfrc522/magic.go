@@ -0,0 +1,304 @@
+package mfrc522
+
+import (
+	"context"
+	"errors"
+)
+
+// Magic is a generation of "magic" cards, which are MIFARE Classic clones that allow changing block 0.
+type Magic byte
+
+// Magic card generations
+const (
+	// MagicNone is a card whose block 0 can't be changed, like a genuine card.
+	MagicNone Magic = iota
+
+	// MagicGen1a cards have a backdoor that allows writing every block (see Gen1a).
+	MagicGen1a
+
+	// MagicGen2 (CUID) cards allow writing block 0 with a regular authenticated WRITE.
+	MagicGen2
+
+	// MagicGen3 cards set the UID and block 0 with APDUs, after they are activated with RATS.
+	MagicGen3
+
+	// MagicGen4 (GTU) cards accept password protected commands that write any block.
+	MagicGen4
+)
+
+// String returns the name of the magic card generation.
+func (m Magic) String() string {
+	switch m {
+	case MagicNone:
+		return "none"
+	case MagicGen1a:
+		return "Gen1a"
+	case MagicGen2:
+		return "Gen2 (CUID)"
+	case MagicGen3:
+		return "Gen3"
+	case MagicGen4:
+		return "Gen4 (GTU)"
+	default:
+		return "unknown"
+	}
+}
+
+// MagicOptions are needed to detect and write some magic card generations.
+type MagicOptions struct {
+	// Keys are tried as key A and key B to authenticate sector 0 of Gen2 cards,
+	// which can't be detected without authentication.
+	Keys [][]byte
+
+	// Password is the password of Gen4 cards. If nil, the default 00 00 00 00 is used.
+	Password []byte
+}
+
+// password returns the Gen4 password.
+func (o MagicOptions) password() []byte {
+	if o.Password == nil {
+		return []byte{0x00, 0x00, 0x00, 0x00}
+	}
+
+	return o.Password
+}
+
+// DetectMagic detects which generation of magic card the selected card is.
+// The generations are tried one after another, and the card is selected again after each
+// attempt, so the session can still be used afterwards. It returns MagicNone if the card
+// didn't behave like any of them (e.g. a Gen2 card, if none of the keys worked).
+//
+// The detection doesn't change the card: Gen2 cards are detected by starting a WRITE to block 0
+// and aborting it with an invalid CRC, and Gen3 cards by their answer to RATS.
+//
+// FUID and UFUID cards, which can be locked, are not told apart from the other generations:
+// a FUID card is reported as Gen2 until block 0 was written once, and a UFUID card as Gen1a
+// until it was locked. Afterwards, both are reported as MagicNone.
+func (s *Session) DetectMagic(opts MagicOptions) (Magic, error) {
+	return s.DetectMagicContext(context.Background(), opts)
+}
+
+// DetectMagicContext is like DetectMagic, but stops when the context is canceled or its deadline expires.
+func (s *Session) DetectMagicContext(ctx context.Context, opts MagicOptions) (Magic, error) {
+	if s.closed {
+		return MagicNone, errors.New("session is closed")
+	}
+
+	detectors := []struct {
+		magic  Magic
+		detect func(context.Context) (bool, error)
+	}{
+		{MagicGen1a, s.IsGen1aContext},
+		{MagicGen4, func(ctx context.Context) (bool, error) { return s.isGen4(ctx, opts.password()) }},
+		{MagicGen3, s.isGen3},
+		{MagicGen2, func(ctx context.Context) (bool, error) { return s.isGen2(ctx, opts.Keys) }},
+	}
+
+	for _, d := range detectors {
+		ok, err := d.detect(ctx)
+		if err != nil {
+			return MagicNone, err
+		}
+		if ok {
+			return d.magic, nil
+		}
+	}
+
+	return MagicNone, nil
+}
+
+// WriteManufacturerBlock writes block 0 of the selected magic card, which holds the UUID.
+// It detects the generation of the card (see DetectMagic) and uses its way of writing block 0.
+// Afterwards, the card is selected again with its new UUID.
+//
+// For cards with a 4-byte UUID, the BCC in the block must be correct, since a card
+// with a wrong BCC can't be selected anymore.
+func (s *Session) WriteManufacturerBlock(data []byte, opts MagicOptions) error {
+	return s.WriteManufacturerBlockContext(context.Background(), data, opts)
+}
+
+// WriteManufacturerBlockContext is like WriteManufacturerBlock, but stops when the context is canceled
+// or its deadline expires.
+func (s *Session) WriteManufacturerBlockContext(ctx context.Context, data []byte, opts MagicOptions) error {
+	if len(data) != 16 {
+		return errors.New("invalid data length, expected 16 bytes")
+	}
+	if len(s.card.UUID) == 4 && data[0]^data[1]^data[2]^data[3] != data[4] {
+		return errors.New("invalid BCC in block 0")
+	}
+
+	magic, err := s.DetectMagicContext(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	switch magic {
+	case MagicGen1a:
+		g, err := s.UnlockGen1aContext(ctx)
+		if err != nil {
+			return err
+		}
+		if err := g.WriteBlockContext(ctx, 0, data); err != nil {
+			_ = g.CloseContext(ctx)
+			return err
+		}
+
+		return g.CloseContext(ctx)
+	case MagicGen2:
+		err = s.writeGen2(ctx, data, opts.Keys)
+	case MagicGen3:
+		err = s.writeGen3(ctx, data)
+	case MagicGen4:
+		err = s.writeGen4(ctx, data, opts.password())
+	default:
+		return errors.New("card doesn't allow writing block 0")
+	}
+
+	if err != nil {
+		if selErr := s.reselect(ctx); selErr != nil {
+			return reselectError{err: err, selErr: selErr}
+		}
+
+		return err
+	}
+
+	s.setUUID(data)
+
+	return s.reselect(ctx)
+}
+
+// isGen2 reports whether the card accepts a WRITE to block 0.
+// The write is aborted by sending the data with an invalid CRC.
+func (s *Session) isGen2(ctx context.Context, keys [][]byte) (bool, error) {
+	ok, err := s.authenticateAny(ctx, 0, keys)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	ack, err := s.m.verifyCRC(ctx, WriteBlockCmd, 0)
+	if err == nil && isACK(ack) {
+		// All zeros is not a valid CRC for all zeros, so the card refuses the data
+		_, _, _ = s.m.transceiveBits(ctx, make([]byte, 18), 0)
+	}
+
+	return err == nil && isACK(ack), s.reselect(ctx)
+}
+
+// writeGen2 writes block 0 of a Gen2 card with a regular authenticated WRITE.
+func (s *Session) writeGen2(ctx context.Context, data []byte, keys [][]byte) error {
+	ok, err := s.authenticateAny(ctx, 0, keys)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("none of the keys authenticated sector 0")
+	}
+
+	return s.m.writeTag(ctx, 0, data)
+}
+
+// gen3ATS is the answer to RATS of Gen3 cards.
+var gen3ATS = []byte{0x09, 0x78, 0x00, 0x91, 0x02, 0xDA, 0xBC, 0x19, 0x10}
+
+// isGen3 reports whether the card answers RATS with the ATS of Gen3 cards.
+// Unlike the Gen3 APDUs, which all write to the card, RATS only activates it.
+func (s *Session) isGen3(ctx context.Context) (bool, error) {
+	if err := s.stopCrypto(); err != nil {
+		return false, err
+	}
+
+	ats, err := s.m.transceiveCRC(ctx, []byte{RequestAnswerToResetCmd, 0x50})
+	if err == nil {
+		_, _ = s.m.transceiveCRC(ctx, []byte{DeselectCmd})
+	}
+
+	return err == nil && string(ats) == string(gen3ATS), s.reselect(ctx)
+}
+
+// writeGen3 writes block 0 of a Gen3 card with an APDU.
+func (s *Session) writeGen3(ctx context.Context, data []byte) error {
+	if err := s.stopCrypto(); err != nil {
+		return err
+	}
+
+	return s.gen3APDU(ctx, Gen3WriteBlock0Cmd, data)
+}
+
+// gen3APDU activates the card with RATS, sends a Gen3 APDU with the instruction and data,
+// and deselects the card. It returns an error if the card didn't answer with the status 90 00.
+//
+// Only a single APDU is sent in each activation, so the block number of the I-block is always 0.
+func (s *Session) gen3APDU(ctx context.Context, ins byte, data []byte) error {
+	ats, err := s.m.transceiveCRC(ctx, []byte{RequestAnswerToResetCmd, 0x50})
+	if err != nil {
+		return err
+	}
+	if len(ats) == 0 {
+		return errors.New("card doesn't support ISO 14443-4")
+	}
+
+	iBlock := append([]byte{0x02, Gen3APDUClass, ins, 0xCC, 0xCC, byte(len(data))}, data...)
+	res, err := s.m.transceiveCRC(ctx, iBlock)
+
+	_, _ = s.m.transceiveCRC(ctx, []byte{DeselectCmd})
+
+	if err != nil {
+		return err
+	}
+	if len(res) != 3 || res[0]&0xE2 != 0x02 || res[1] != 0x90 || res[2] != 0x00 {
+		return errors.New("card refused the APDU")
+	}
+
+	return nil
+}
+
+// isGen4 reports whether the card returns its configuration for the Gen4 password.
+func (s *Session) isGen4(ctx context.Context, password []byte) (bool, error) {
+	if err := s.stopCrypto(); err != nil {
+		return false, err
+	}
+
+	res, err := s.m.transceiveCRC(ctx, append(append([]byte{Gen4Cmd}, password...), Gen4GetConfigCmd))
+	if err == nil && (len(res) == 30 || len(res) == 32) {
+		return true, nil
+	}
+
+	// Other cards leave the selected state when they don't understand a command
+	return false, s.reselect(ctx)
+}
+
+// writeGen4 writes block 0 of a Gen4 card with the password protected write command.
+func (s *Session) writeGen4(ctx context.Context, data, password []byte) error {
+	if err := s.stopCrypto(); err != nil {
+		return err
+	}
+
+	cmd := append(append([]byte{Gen4Cmd}, password...), Gen4WriteBlockCmd, 0)
+	res, err := s.m.transceiveCRC(ctx, append(cmd, data...))
+	if err != nil {
+		return err
+	}
+	if len(res) != 2 || res[0] != 0x90 || res[1] != 0x00 {
+		return errors.New("card refused the write")
+	}
+
+	return nil
+}
+
+// authenticateAny authenticates the sector of the block address with the first key that works,
+// trying all keys as key A and then as key B. It reports false if none of them worked.
+func (s *Session) authenticateAny(ctx context.Context, addr byte, keys [][]byte) (bool, error) {
+	for _, authMode := range []byte{AuthKeyACmd, AuthKeyBCmd} {
+		for _, key := range keys {
+			err := s.AuthenticateContext(ctx, authMode, addr, key)
+			if err == nil {
+				return true, nil
+			}
+			if s.closed {
+				return false, err
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -1,63 +1,149 @@
 package mfrc522
 
 import (
+	"context"
 	"errors"
-	"machine"
+	"strconv"
 	"time"
 )
 
 // MFRC522 holds the relevant configuration for the MFRC522 RFID reader.
 type MFRC522 struct {
-	// SPI is used to communicate with the MFRC522 reader.
-	// This is technically the host's SPI interface.
-	spi machine.SPI
+	// bus is used to communicate with the MFRC522 reader.
+	// This is usually the host's SPI interface.
+	bus Bus
 
 	// rstPin is the reset pin for the MFRC522 reader.
-	// It is used to initialize the reader.
-	rstPin machine.Pin
+	// It is used to initialize the reader and can be nil if the pin is tied high.
+	rstPin Pin
 
 	// irqPin is the interrupt pin for the MFRC522 reader.
-	// It notifies the host when a card is present.
-	irqPin machine.Pin
+	// It notifies the host when a card is present. It can be nil if the pin is not connected,
+	// in which case the interrupt register is polled instead.
+	irqPin Pin
 
 	// irqTimeout is the maximum time to wait for an interrupt from the reader.
 	// The interrupt signals that a card is present.
 	irqTimeout time.Duration
+
+	// pollInterval is the time between two reads of the interrupt register,
+	// if there is no interrupt pin.
+	pollInterval time.Duration
+
+	// commandPollInterval is the time between two reads of the interrupt register while
+	// waiting for a command to finish, if there is no interrupt pin.
+	commandPollInterval time.Duration
+
+	// irq receives a value when the level of the interrupt pin changes.
+	// It is nil if there is no interrupt pin.
+	irq chan struct{}
+
+	// gain is the receiver gain, which is written when the reader is initialized
+	// if hasGain is set.
+	gain    Gain
+	hasGain bool
 }
 
-// Init initializes the MFRC522 reader.
-func Init(rstPin, irqPin machine.Pin, irqTimeout time.Duration) (*MFRC522, error) {
+// Option configures the reader in New and Init.
+type Option func(m *MFRC522)
+
+// DefaultPollInterval is the time between two reads of the interrupt register,
+// if the reader has no interrupt pin (see SetPollInterval).
+const DefaultPollInterval = 5 * time.Millisecond
+
+// DefaultCommandPollInterval is the time between two reads of the interrupt register while
+// waiting for a command to finish, if the reader has no interrupt pin (see SetCommandPollInterval).
+const DefaultCommandPollInterval = time.Millisecond
+
+// New initializes the MFRC522 reader connected through the given bus.
+// The reset and interrupt pins can be nil if they are not connected.
+// Without an interrupt pin, the reader's interrupt register is polled instead.
+func New(bus Bus, rstPin, irqPin Pin, irqTimeout time.Duration, opts ...Option) (*MFRC522, error) {
 	mfrc522 := &MFRC522{
-		spi:        machine.SPI0,
-		rstPin:     rstPin,
-		irqPin:     irqPin,
-		irqTimeout: irqTimeout,
+		bus:                 bus,
+		rstPin:              rstPin,
+		irqPin:              irqPin,
+		irqTimeout:          irqTimeout,
+		pollInterval:        DefaultPollInterval,
+		commandPollInterval: DefaultCommandPollInterval,
 	}
-
-	if err := mfrc522.spi.Configure(machine.SPIConfig{Frequency: 1000000}); err != nil {
-		return nil, errors.New("failed to configure SPI" + err.Error())
+	for _, opt := range opts {
+		opt(mfrc522)
+	}
+	if mfrc522.hasGain && !mfrc522.gain.valid() {
+		return nil, errors.New("invalid antenna gain " + strconv.Itoa(int(mfrc522.gain)))
 	}
 
-	mfrc522.rstPin.Configure(machine.PinConfig{Mode: machine.PinInput})
-	if !mfrc522.rstPin.Get() {
-		mfrc522.rstPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-		mfrc522.rstPin.Low()
+	if mfrc522.rstPin != nil && !mfrc522.rstPin.Get() {
+		mfrc522.rstPin.Set(false)
 		time.Sleep(2 * time.Microsecond)
-		mfrc522.rstPin.High()
+		mfrc522.rstPin.Set(true)
 		time.Sleep(50 * time.Microsecond)
 	}
 
-	if err := mfrc522.WriteSequence(InitSequence); err != nil {
-		return nil, errors.New("Failed to write initialization sequence:" + err.Error())
+	if err := mfrc522.setup(); err != nil {
+		return nil, err
 	}
 
-	if err := mfrc522.AntennaOn(); err != nil {
-		return nil, errors.New("Failed to turn on antenna:" + err.Error())
+	if mfrc522.irqPin != nil {
+		irq := make(chan struct{}, 1)
+		if err := mfrc522.irqPin.SetInterrupt(func() {
+			select {
+			case irq <- struct{}{}:
+			default:
+			}
+		}); err != nil {
+			return nil, errors.New("Failed to set interrupt:" + err.Error())
+		}
+		mfrc522.irq = irq
 	}
 
 	return mfrc522, nil
 }
 
+// setup writes the initialization sequence and the configured gain, and turns on the antenna,
+// which is needed again after a reset of the reader.
+func (m *MFRC522) setup() error {
+	if err := m.WriteSequence(InitSequence); err != nil {
+		return errors.New("Failed to write initialization sequence:" + err.Error())
+	}
+
+	if m.hasGain {
+		if err := m.writeGain(m.gain); err != nil {
+			return errors.New("Failed to set antenna gain:" + err.Error())
+		}
+	}
+
+	if err := m.AntennaOn(); err != nil {
+		return errors.New("Failed to turn on antenna:" + err.Error())
+	}
+
+	return nil
+}
+
+// SetPollInterval sets the time between two reads of the interrupt register, which is used
+// to wait for a card if the reader has no interrupt pin. Shorter intervals notice a card sooner,
+// but use more of the bus. It has no effect if there is an interrupt pin.
+func (m *MFRC522) SetPollInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	m.pollInterval = interval
+}
+
+// SetCommandPollInterval sets the time between two reads of the interrupt register, which is used
+// to wait for a command (e.g. a tag command or a CRC calculation) to finish if the reader has no
+// interrupt pin. These commands take about a millisecond, so the interval is shorter than the one
+// for waiting for a card. It has no effect if there is an interrupt pin.
+func (m *MFRC522) SetCommandPollInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultCommandPollInterval
+	}
+
+	m.commandPollInterval = interval
+}
+
 // Version returns the firmware version of the MFRC522 reader.
 func (m *MFRC522) Version() (byte, error) {
 	ver, err := m.ReadRegister(VersionReg)
@@ -70,14 +156,74 @@ func (m *MFRC522) Version() (byte, error) {
 
 // ReadTagUUID returns the UUID of the selected RFID tag.
 func (m *MFRC522) ReadTagUUID() ([]byte, error) {
-	var err error
-	defer func() {
-		if err == nil {
-			err = m.StopCrypto()
+	return m.ReadTagUUIDContext(context.Background())
+}
+
+// ReadTagUUIDContext is like ReadTagUUID, but stops when the context is canceled or its deadline expires.
+func (m *MFRC522) ReadTagUUIDContext(ctx context.Context) ([]byte, error) {
+	card, err := m.ReadCardContext(ctx)
+
+	return card.UUID, err
+}
+
+// ReadCard returns the identification of the selected RFID tag, which includes
+// the UUID and the ATQA and SAK it answered with.
+func (m *MFRC522) ReadCard() (CardInfo, error) {
+	return m.ReadCardContext(context.Background())
+}
+
+// ReadCardContext is like ReadCard, but stops when the context is canceled or its deadline expires.
+func (m *MFRC522) ReadCardContext(ctx context.Context) (CardInfo, error) {
+	s, err := m.SelectContext(ctx)
+	if err != nil {
+		return CardInfo{}, err
+	}
+
+	return s.Card(), s.Close()
+}
+
+// Inventory selects every card in the RF field and returns their identification.
+// Each card is halted after it is selected, so it doesn't answer again until it is
+// woken up (e.g. by taking it out of the field). Cards that were already halted are
+// not found.
+//
+// If several cards answered the same request, their ATQA has the bits of all the answers set.
+func (m *MFRC522) Inventory() ([]CardInfo, error) {
+	return m.InventoryContext(context.Background())
+}
+
+// InventoryContext is like Inventory, but stops when the context is canceled or its deadline expires.
+// The cards found until then are returned with the context's error.
+func (m *MFRC522) InventoryContext(ctx context.Context) ([]CardInfo, error) {
+	defer func() { _ = m.ClearIRQ() }()
+
+	var cards []CardInfo
+	for {
+		atqa, ok, err := m.request(ctx, RequestACmd)
+		if err != nil {
+			return cards, err
+		}
+		if !ok {
+			return cards, nil
+		}
+
+		uuid, sak, err := m.cascade(ctx)
+		if err != nil {
+			return cards, err
+		}
+
+		// A card that ignores HLTA would otherwise be found forever
+		for _, card := range cards {
+			if string(card.UUID) == string(uuid) {
+				return cards, wrap("card did not halt", ErrProtocol)
+			}
 		}
-	}()
+		cards = append(cards, CardInfo{UUID: uuid, ATQA: atqa, SAK: sak})
 
-	return m.selectCard()
+		if err := m.halt(ctx); err != nil {
+			return cards, err
+		}
+	}
 }
 
 // Reset sends a soft reset command to the MFRC522 reader.
@@ -103,11 +249,87 @@ func (m *MFRC522) Reset() error {
 	return nil
 }
 
+// powerUpTimeout is the maximum time to wait for the oscillator to restart after power-down.
+const powerUpTimeout = 500 * time.Millisecond
+
+// PowerDown puts the MFRC522 reader into the soft power-down mode, in which it only draws a
+// few microamperes. The RF field is off, so cards in it lose their state and need to be
+// selected again after PowerUp.
+//
+// Starting any command clears the PowerDown bit without waiting for the oscillator,
+// so PowerUp must be called before the reader is used again.
+func (m *MFRC522) PowerDown() error {
+	val, err := m.ReadRegister(CommandReg)
+	if err != nil {
+		return err
+	}
+
+	return m.WriteRegister(CommandReg, val&0x20|0x10|NoCmdChangeCmd)
+}
+
+// PowerUp wakes the MFRC522 reader from the soft power-down mode. It waits for the oscillator
+// to restart, and then initializes the reader again with the initialization sequence and the
+// configured gain, like New.
+func (m *MFRC522) PowerUp() error {
+	return m.PowerUpContext(context.Background())
+}
+
+// PowerUpContext is like PowerUp, but stops when the context is canceled or its deadline expires.
+func (m *MFRC522) PowerUpContext(ctx context.Context) error {
+	val, err := m.ReadRegister(CommandReg)
+	if err != nil {
+		return err
+	}
+	if err := m.WriteRegister(CommandReg, val&0x20|NoCmdChangeCmd); err != nil {
+		return err
+	}
+
+	// The PowerDown bit reads 1 until the oscillator is running again
+	timer := time.NewTimer(powerUpTimeout)
+	defer timer.Stop()
+
+	for {
+		val, err := m.ReadRegister(CommandReg)
+		if err != nil {
+			return err
+		}
+		if val&0x10 == 0 {
+			break
+		}
+
+		select {
+		case <-time.After(time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return wrap("waiting for the oscillator", ErrTimeout)
+		}
+	}
+
+	return m.setup()
+}
+
 // Exit handles the cleanup of the MFRC522 reader.
 func (m *MFRC522) Exit() {
 	if err := m.AntennaOff(); err != nil {
 		println("Failed to turn off antenna:", err)
 	}
+
+	if m.irqPin != nil {
+		if err := m.irqPin.SetInterrupt(nil); err != nil {
+			println("Failed to disable interrupt:", err)
+		}
+	}
+}
+
+// ReadRegisterBytes allows reading multiple bytes from a register.
+func (m *MFRC522) ReadRegisterBytes(reg Register, readLen int) ([]byte, error) {
+	return m.bus.ReadRegisterBytes(reg, readLen)
+}
+
+// WriteRegisterBytes allows writing multiple bytes to a register.
+func (m *MFRC522) WriteRegisterBytes(reg Register, val []byte) error {
+	return m.bus.WriteRegisterBytes(reg, val)
 }
 
 // WriteRegister writes a byte to the specified register.
@@ -165,46 +387,50 @@ func (m *MFRC522) ClearBitmask(reg Register, mask byte) error {
 }
 
 // WaitForInterrupt waits for an interrupt from the MFRC522 reader, which
-// signals that a tag is present.
+// signals that a tag is present. It sends WUPA, so halted tags are found as well.
+// It returns ErrNoCard if no tag answered within the timeout.
+// Without an interrupt pin, the interrupt register is polled instead (see SetPollInterval).
 func (m *MFRC522) WaitForInterrupt(timeout time.Duration) error {
-	irqChan := make(chan bool, 1)
-	defer close(irqChan)
-
-	irqFunc := func(p machine.Pin) { irqChan <- true }
-	if err := m.irqPin.SetInterrupt(machine.PinToggle, irqFunc); err != nil {
-		return err
-	}
+	return m.WaitForInterruptContext(context.Background(), timeout)
+}
 
+// WaitForInterruptContext is like WaitForInterrupt, but returns the context's error
+// as soon as the context is canceled or its deadline expires.
+func (m *MFRC522) WaitForInterruptContext(ctx context.Context, timeout time.Duration) error {
 	if err := m.WriteSequence([]WriteCommand{
-		{ComIrqReg, 0x00},
+		{ComIrqReg, 0x7F},
+		{DivIrqReg, 0x7F},
 		{ComIEnReg, 0xA0},
 	}); err != nil {
 		return err
 	}
 
-	start := time.Now()
-	for start.Before(start.Add(timeout)) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
 		if err := m.WriteSequence([]WriteCommand{
-			{FIFODataReg, 0x26},
+			{FIFODataReg, WakeUpACmd},
 			{CommandReg, TransceiveCmd},
 			{BitFramingReg, 0x87},
 		}); err != nil {
 			return err
 		}
 
-		select {
-		case <-irqChan:
-			return nil
-		case <-time.After(100 * time.Millisecond):
+		// Without an answer, the request is sent again
+		_, err := m.waitForIRQ(ctx, ComIrqReg, 0x20, 100*time.Millisecond, m.pollInterval)
+		if !errors.Is(err, ErrTimeout) {
+			return err
 		}
 	}
 
-	return nil
+	return wrap("no interrupt within the timeout", ErrNoCard)
 }
 
 // ClearIRQ clears the interrupt request bits.
 func (m *MFRC522) ClearIRQ() error {
-	return m.WaitForInterrupt(0)
+	return m.WriteSequence([]WriteCommand{
+		{ComIrqReg, 0x7F},
+		{DivIrqReg, 0x7F},
+	})
 }
 
 // StopCrypto stops the crypto1 unit, which is needed after entering an authenticated state.
@@ -213,111 +439,93 @@ func (m *MFRC522) StopCrypto() error {
 }
 
 // ReadAuthentication reads the tag's authentication data from the specified sector.
+// Use Select to read more than one block.
 func (m *MFRC522) ReadAuthentication(authMode, sector byte, key []byte) ([]byte, error) {
-	var err error
-	defer func() {
-		if err == nil {
-			err = m.StopCrypto()
-		}
-	}()
+	return m.ReadAuthenticationContext(context.Background(), authMode, sector, key)
+}
 
-	uuid, err := m.selectCard()
+// ReadAuthenticationContext is like ReadAuthentication, but stops when the context is canceled
+// or its deadline expires.
+func (m *MFRC522) ReadAuthenticationContext(ctx context.Context, authMode, sector byte, key []byte) ([]byte, error) {
+	addr, err := blockAddr(sector, SectorBlocks(sector)-1)
 	if err != nil {
 		return nil, err
 	}
 
-	addr := sector*4 + 3
-
-	auth, err := m.authenticate(authMode, addr, key, uuid)
+	s, err := m.SelectContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if auth != AuthOk {
-		return nil, errors.New("authentication failed")
+	defer func() { _ = s.Close() }()
+
+	if err := s.AuthenticateContext(ctx, authMode, addr, key); err != nil {
+		return nil, err
 	}
 
-	return m.readTag(addr)
+	return s.ReadBlockContext(ctx, addr)
 }
 
 // ReadTagBlock reads a block of data from the specified address (sector+block).
+// Use Select to read more than one block.
 func (m *MFRC522) ReadTagBlock(authMode, sector, block byte, key []byte) ([]byte, error) {
-	var err error
-	defer func() {
-		if err == nil {
-			err = m.StopCrypto()
-		}
-	}()
+	return m.ReadTagBlockContext(context.Background(), authMode, sector, block, key)
+}
 
-	uuid, err := m.selectCard()
+// ReadTagBlockContext is like ReadTagBlock, but stops when the context is canceled or its deadline expires.
+func (m *MFRC522) ReadTagBlockContext(ctx context.Context, authMode, sector, block byte, key []byte) ([]byte, error) {
+	addr, err := blockAddr(sector, block)
 	if err != nil {
 		return nil, err
 	}
 
-	auth, err := m.authenticate(authMode, sector*4+block, key, uuid)
+	s, err := m.SelectContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if auth != AuthOk {
-		return nil, errors.New("authentication failed")
+	defer func() { _ = s.Close() }()
+
+	if err := s.AuthenticateContext(ctx, authMode, addr, key); err != nil {
+		return nil, err
 	}
 
-	return m.readTag(sector*4 + (block % 3))
+	return s.ReadBlockContext(ctx, addr)
 }
 
-// WriteTag writes data to the specified address (sector+block).
-func (m *MFRC522) WriteTag(authMode, sector, block byte, data, key []byte) error {
-	var err error
-	defer func() {
-		if err == nil {
-			err = m.StopCrypto()
-		}
-	}()
+// WriteTag writes the 16 bytes of data to the specified address (sector+block),
+// after authenticating the block's sector with the key.
+// If verify is set, the block is read back and ErrMismatch is returned if it differs.
+// Use Select to write more than one block.
+func (m *MFRC522) WriteTag(authMode, sector, block byte, data, key []byte, verify bool) error {
+	return m.WriteTagContext(context.Background(), authMode, sector, block, data, key, verify)
+}
 
-	uuid, err := m.selectCard()
+// WriteTagContext is like WriteTag, but stops when the context is canceled or its deadline expires.
+func (m *MFRC522) WriteTagContext(ctx context.Context, authMode, sector, block byte, data, key []byte,
+	verify bool,
+) error {
+	addr, err := blockAddr(sector, block)
 	if err != nil {
 		return err
 	}
+	if len(data) != 16 {
+		return errors.New("invalid data length, expected 16 bytes")
+	}
 
-	auth, err := m.authenticate(authMode, sector*4+3, key, uuid)
+	s, err := m.SelectContext(ctx)
 	if err != nil {
 		return err
 	}
-	if auth != AuthOk {
-		return errors.New("authentication failed")
-	}
-
-	return m.writeTag(sector*4+(block%3), data)
-}
+	defer func() { _ = s.Close() }()
 
-/*
-	Not-implemented section
-
-	These functions are not needed for the basic functionality of the RFID reader,
-	so they were not implemented for the lab exercise, but might be in the future.
-*/
-
-// SetAntennaGain sets the antenna gain of the MFRC522 reader.
-func (m *MFRC522) SetAntennaGain() error {
-	return errors.New("not implemented")
-}
-
-// AntennaGain returns the current antenna gain of the MFRC522 reader.
-func (m *MFRC522) AntennaGain() error {
-	return errors.New("not implemented")
-}
-
-// SelfTest performs a self-test on the MFRC522 reader
-// (specified in Chapter 16.1.1 of the MFRC55 datasheet).
-func (m *MFRC522) SelfTest() (bool, error) {
-	return false, errors.New("not implemented")
-}
-
-// PowerDown puts the MFRC522 reader into power-down mode.
-func (m *MFRC522) PowerDown() error {
-	return errors.New("not implemented")
-}
+	if err := s.AuthenticateContext(ctx, authMode, addr, key); err != nil {
+		return err
+	}
+	if err := s.WriteBlockContext(ctx, addr, data); err != nil {
+		return err
+	}
+	if !verify {
+		return nil
+	}
 
-// PowerUp wakes the MFRC522 reader from power-down mode.
-func (m *MFRC522) PowerUp() error {
-	return errors.New("not implemented")
+	return s.VerifyBlockContext(ctx, addr, data)
 }
@@ -0,0 +1,466 @@
+package mfrc522_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+	"github.com/msthtrifork/gorfid/mfrc522/sim"
+)
+
+// newReader creates a reader connected to a simulated chip with the targets in its RF field.
+func newReader(t *testing.T, targets ...sim.Target) (*mfrc522.MFRC522, *sim.Chip) {
+	t.Helper()
+
+	c := sim.NewChip()
+	for _, target := range targets {
+		c.Add(target)
+	}
+
+	m, err := mfrc522.New(c, c.RST(), c.IRQ(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	return m, c
+}
+
+// newCard creates a blank simulated MIFARE Classic card.
+func newCard(t *testing.T, typ sim.ClassicType, uid ...byte) *sim.Classic {
+	t.Helper()
+
+	card, err := sim.NewClassic(typ, uid)
+	if err != nil {
+		t.Fatalf("NewClassic() error = %v", err)
+	}
+
+	return card
+}
+
+func TestVersion(t *testing.T) {
+	m, _ := newReader(t)
+
+	ver, err := m.Version()
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+	if ver != 0x92 {
+		t.Errorf("Version() = %02x, want 92", ver)
+	}
+}
+
+func TestReadCard(t *testing.T) {
+	tests := []struct {
+		name   string
+		typ    sim.ClassicType
+		uid    []byte
+		family mfrc522.CardFamily
+	}{
+		{"single size 1K", sim.Classic1K, []byte{0xDE, 0xAD, 0xBE, 0xEF}, mfrc522.FamilyClassic1K},
+		{"single size mini", sim.ClassicMini, []byte{0x12, 0x34, 0x56, 0x78}, mfrc522.FamilyClassicMini},
+		{"double size 4K", sim.Classic4K, []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, mfrc522.FamilyClassic4K},
+		{"triple size 1K", sim.Classic1K, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A},
+			mfrc522.FamilyClassic1K},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newReader(t, newCard(t, tt.typ, tt.uid...))
+
+			card, err := m.ReadCard()
+			if err != nil {
+				t.Fatalf("ReadCard() error = %v", err)
+			}
+			if !bytes.Equal(card.UUID, tt.uid) {
+				t.Errorf("ReadCard().UUID = % x, want % x", card.UUID, tt.uid)
+			}
+			if card.UUIDLength() != len(tt.uid) {
+				t.Errorf("ReadCard().UUIDLength() = %d, want %d", card.UUIDLength(), len(tt.uid))
+			}
+			if card.Family() != tt.family {
+				t.Errorf("ReadCard().Family() = %v, want %v", card.Family(), tt.family)
+			}
+		})
+	}
+}
+
+func TestReadCardNoCard(t *testing.T) {
+	m, _ := newReader(t)
+
+	if _, err := m.ReadCard(); !errors.Is(err, mfrc522.ErrNoCard) {
+		t.Errorf("ReadCard() error = %v, want ErrNoCard", err)
+	}
+}
+
+func TestReadCardCollision(t *testing.T) {
+	// The UUIDs first differ in bit 0 of byte 3, where the anti-collision continues with the set bit
+	m, _ := newReader(t,
+		newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xE0),
+		newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF),
+	)
+
+	card, err := m.ReadCard()
+	if err != nil {
+		t.Fatalf("ReadCard() error = %v", err)
+	}
+	if want := []byte{0xDE, 0xAD, 0xBE, 0xEF}; !bytes.Equal(card.UUID, want) {
+		t.Errorf("ReadCard().UUID = % x, want % x", card.UUID, want)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	uids := [][]byte{
+		{0xDE, 0xAD, 0xBE, 0xEF},
+		{0xDE, 0xAD, 0xBE, 0xE0},
+		{0x12, 0x34, 0x56, 0x78},
+		{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+		{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A},
+	}
+
+	var targets []sim.Target
+	for _, uid := range uids {
+		targets = append(targets, newCard(t, sim.Classic1K, uid...))
+	}
+	m, _ := newReader(t, targets...)
+
+	cards, err := m.Inventory()
+	if err != nil {
+		t.Fatalf("Inventory() error = %v", err)
+	}
+
+	var got [][]byte
+	for _, card := range cards {
+		got = append(got, card.UUID)
+	}
+	slices.SortFunc(got, bytes.Compare)
+	slices.SortFunc(uids, bytes.Compare)
+	if !slices.EqualFunc(got, uids, bytes.Equal) {
+		t.Errorf("Inventory() found % x, want % x", got, uids)
+	}
+
+	// The cards were halted, so they are only found again after they are woken up
+	cards, err = m.Inventory()
+	if err != nil || len(cards) != 0 {
+		t.Errorf("second Inventory() = %d cards, %v, want none", len(cards), err)
+	}
+}
+
+func TestWriteTag(t *testing.T) {
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	m, _ := newReader(t, card)
+
+	data := []byte("0123456789abcdef")
+	if err := m.WriteTag(mfrc522.AuthKeyACmd, 1, 2, data, sim.DefaultKey, true); err != nil {
+		t.Fatalf("WriteTag() error = %v", err)
+	}
+	if block := card.Block(6); !bytes.Equal(block[:], data) {
+		t.Errorf("card block 6 = % x, want % x", block, data)
+	}
+
+	got, err := m.ReadTagBlock(mfrc522.AuthKeyACmd, 1, 2, sim.DefaultKey)
+	if err != nil {
+		t.Fatalf("ReadTagBlock() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("ReadTagBlock() = % x, want % x", got, data)
+	}
+}
+
+func TestWriteTagErrors(t *testing.T) {
+	data := []byte("0123456789abcdef")
+
+	tests := []struct {
+		name   string
+		sector byte
+		block  byte
+		data   []byte
+		key    []byte
+		nak    bool
+		err    error
+	}{
+		{"block 0", 0, 0, data, sim.DefaultKey, true, nil},
+		{"no write access", 2, 0, data, sim.DefaultKey, true, nil},
+		{"wrong key", 1, 0, data, []byte{1, 2, 3, 4, 5, 6}, false, mfrc522.ErrAuth},
+		{"short data", 1, 0, data[:3], sim.DefaultKey, false, nil},
+		{"invalid block", 1, 4, data, sim.DefaultKey, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+			card.SetTrailer(2, sim.DefaultKey, noReadAccess, sim.DefaultKey)
+			dump := card.Dump()
+			m, _ := newReader(t, card)
+
+			err := m.WriteTag(mfrc522.AuthKeyACmd, tt.sector, tt.block, tt.data, tt.key, true)
+			if err == nil {
+				t.Fatal("WriteTag() succeeded")
+			}
+
+			var nak mfrc522.ErrNAK
+			if errors.As(err, &nak) != tt.nak {
+				t.Errorf("WriteTag() error = %v, want ErrNAK: %t", err, tt.nak)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("WriteTag() error = %v, want %v", err, tt.err)
+			}
+			if !bytes.Equal(card.Dump(), dump) {
+				t.Error("WriteTag() changed the card")
+			}
+		})
+	}
+}
+
+func TestReadTagBlockNAK(t *testing.T) {
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	card.SetTrailer(1, sim.DefaultKey, noReadAccess, sim.DefaultKey)
+	m, _ := newReader(t, card)
+
+	var nak mfrc522.ErrNAK
+	if _, err := m.ReadTagBlock(mfrc522.AuthKeyACmd, 1, 0, sim.DefaultKey); !errors.As(err, &nak) {
+		t.Errorf("ReadTagBlock() error = %v, want ErrNAK", err)
+	}
+}
+
+func TestPowerDownPowerUp(t *testing.T) {
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	c := sim.NewChip()
+	c.Add(card)
+	m, err := mfrc522.New(c, c.RST(), c.IRQ(), 200*time.Millisecond, mfrc522.WithAntennaGain(mfrc522.Gain43dB))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Inventory halts the card, so it is only found again after it lost power
+	if cards, err := m.Inventory(); err != nil || len(cards) != 1 {
+		t.Fatalf("Inventory() = %d cards, %v, want 1", len(cards), err)
+	}
+
+	if err := m.PowerDown(); err != nil {
+		t.Fatalf("PowerDown() error = %v", err)
+	}
+	if val, err := m.ReadRegister(mfrc522.CommandReg); err != nil || val&0x10 == 0 {
+		t.Errorf("CommandReg after PowerDown() = %02x, %v, want the PowerDown bit set", val, err)
+	}
+
+	if err := m.PowerUp(); err != nil {
+		t.Fatalf("PowerUp() error = %v", err)
+	}
+	if val, err := m.ReadRegister(mfrc522.CommandReg); err != nil || val&0x10 != 0 {
+		t.Errorf("CommandReg after PowerUp() = %02x, %v, want the PowerDown bit cleared", val, err)
+	}
+	if gain, err := m.AntennaGain(); err != nil || gain != mfrc522.Gain43dB {
+		t.Errorf("AntennaGain() after PowerUp() = %v, %v, want %v", gain, err, mfrc522.Gain43dB)
+	}
+
+	cards, err := m.Inventory()
+	if err != nil || len(cards) != 1 || !bytes.Equal(cards[0].UUID, card.UID()) {
+		t.Errorf("Inventory() after PowerUp() = %d cards, %v, want the card again", len(cards), err)
+	}
+}
+
+func TestPowerUpAwake(t *testing.T) {
+	m, _ := newReader(t, newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF))
+
+	if err := m.PowerUp(); err != nil {
+		t.Fatalf("PowerUp() of an awake reader error = %v", err)
+	}
+	if _, err := m.ReadCard(); err != nil {
+		t.Errorf("ReadCard() error = %v", err)
+	}
+}
+
+func TestWithoutIRQPin(t *testing.T) {
+	tests := []struct {
+		name string
+		irq  bool
+	}{
+		{"interrupt pin", true},
+		{"no interrupt pin", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+			card.SetBlock(4, [16]byte{0x01, 0x02, 0x03})
+			c := sim.NewChip()
+			c.Add(card)
+			var irq mfrc522.Pin
+			if tt.irq {
+				irq = c.IRQ()
+			}
+			m, err := mfrc522.New(c, c.RST(), irq, 200*time.Millisecond)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			info, err := m.ReadCard()
+			if err != nil {
+				t.Fatalf("ReadCard() error = %v", err)
+			}
+			if !bytes.Equal(info.UUID, card.UID()) || info.Family() != mfrc522.FamilyClassic1K {
+				t.Errorf("ReadCard() = % x, %v, want % x, %v",
+					info.UUID, info.Family(), card.UID(), mfrc522.FamilyClassic1K)
+			}
+
+			s := selectCard(t, m)
+			if err := s.Authenticate(mfrc522.AuthKeyACmd, 4, sim.DefaultKey); err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			data, err := s.ReadBlock(4)
+			if err != nil {
+				t.Fatalf("ReadBlock() error = %v", err)
+			}
+			if want := card.Block(4); !bytes.Equal(data, want[:]) {
+				t.Errorf("ReadBlock() = % x, want % x", data, want)
+			}
+			write := bytes.Repeat([]byte{0x5A}, 16)
+			if err := s.WriteBlock(5, write); err != nil {
+				t.Fatalf("WriteBlock() error = %v", err)
+			}
+			if got := card.Block(5); !bytes.Equal(got[:], write) {
+				t.Errorf("block 5 after WriteBlock() = % x, want % x", got, write)
+			}
+		})
+	}
+}
+
+// slowBus is a simulated chip whose commands take some time, like on a real reader: the interrupt
+// request registers only show that a command finished once busy has passed since it started.
+type slowBus struct {
+	*sim.Chip
+
+	busy time.Duration
+
+	mu sync.Mutex
+
+	// started is when the running command started.
+	started time.Time
+
+	// commands is the number of started commands, and reads the number of reads of the
+	// interrupt request registers.
+	commands, reads int
+}
+
+// WriteRegisterBytes writes to the simulated chip, and notes the start of a command.
+func (b *slowBus) WriteRegisterBytes(reg mfrc522.Register, val []byte) error {
+	if reg == mfrc522.CommandReg && len(val) > 0 && val[len(val)-1]&0x0F != mfrc522.IdleCmd {
+		b.mu.Lock()
+		b.started = time.Now()
+		b.commands++
+		b.mu.Unlock()
+	}
+
+	return b.Chip.WriteRegisterBytes(reg, val)
+}
+
+// ReadRegisterBytes reads from the simulated chip, and hides the interrupt requests while the command runs.
+func (b *slowBus) ReadRegisterBytes(reg mfrc522.Register, readLen int) ([]byte, error) {
+	val, err := b.Chip.ReadRegisterBytes(reg, readLen)
+	if err != nil || reg != mfrc522.ComIrqReg && reg != mfrc522.DivIrqReg {
+		return val, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.reads++
+	if time.Since(b.started) < b.busy {
+		val[0] = 0
+	}
+
+	return val, nil
+}
+
+// counts returns the number of started commands and reads of the interrupt request registers.
+func (b *slowBus) counts() (commands, reads int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.commands, b.reads
+}
+
+func TestSetCommandPollInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		min, max int
+	}{
+		// The commands take 20 ms, so they are polled about 20 times with the default interval,
+		// but only twice with a longer one. Tag commands read the register once more before they start.
+		{"default", 0, 6, 30},
+		{"negative", -time.Millisecond, 6, 30},
+		{"25ms", 25 * time.Millisecond, 1, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+			c := sim.NewChip()
+			c.Add(card)
+			bus := &slowBus{Chip: c, busy: 20 * time.Millisecond}
+			m, err := mfrc522.New(bus, c.RST(), nil, 200*time.Millisecond)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			m.SetCommandPollInterval(tt.interval)
+
+			info, err := m.ReadCard()
+			if err != nil {
+				t.Fatalf("ReadCard() error = %v", err)
+			}
+			if !bytes.Equal(info.UUID, card.UID()) {
+				t.Errorf("ReadCard().UUID = % x, want % x", info.UUID, card.UID())
+			}
+
+			commands, reads := bus.counts()
+			if commands == 0 || reads < tt.min*commands || reads > tt.max*commands {
+				t.Errorf("ReadCard() read the interrupt requests %d times for %d commands, want %d to %d per command",
+					reads, commands, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestSetPollInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		min, max int
+	}{
+		// Without a card, the interrupt register is polled until the timeout of 100 ms
+		{"default", 0, 10, 22},
+		{"25ms", 25 * time.Millisecond, 2, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := sim.NewChip()
+			bus := &slowBus{Chip: c}
+			m, err := mfrc522.New(bus, c.RST(), nil, 200*time.Millisecond)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			m.SetPollInterval(tt.interval)
+
+			_, before := bus.counts()
+			if err := m.WaitForInterrupt(100 * time.Millisecond); !errors.Is(err, mfrc522.ErrNoCard) {
+				t.Fatalf("WaitForInterrupt() without a card error = %v, want ErrNoCard", err)
+			}
+			if _, after := bus.counts(); after-before < tt.min || after-before > tt.max {
+				t.Errorf("WaitForInterrupt() read the interrupt requests %d times, want %d to %d",
+					after-before, tt.min, tt.max)
+			}
+
+			// A card is still found with the interval
+			c.Add(newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF))
+			if err := m.WaitForInterrupt(100 * time.Millisecond); err != nil {
+				t.Errorf("WaitForInterrupt() error = %v", err)
+			}
+		})
+	}
+}
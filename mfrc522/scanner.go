@@ -0,0 +1,189 @@
+package mfrc522
+
+import (
+	"context"
+	"time"
+)
+
+// ScanOptions configure the duty cycle of a Scanner.
+type ScanOptions struct {
+	// Period is the time between the start of two probes. If zero, 500 ms is used.
+	Period time.Duration
+
+	// Settle is the time the RF field is on before the probe is sent, which the cards need
+	// to power up. If zero, 5 ms is used.
+	Settle time.Duration
+
+	// PowerDown puts the reader into the soft power-down mode between probes, instead of
+	// only turning off the antenna. This saves more power, but each probe takes longer,
+	// because the reader's oscillator has to restart.
+	PowerDown bool
+}
+
+// period returns the time between the start of two probes.
+func (o ScanOptions) period() time.Duration {
+	if o.Period <= 0 {
+		return 500 * time.Millisecond
+	}
+
+	return o.Period
+}
+
+// settle returns the time the RF field is on before the probe.
+func (o ScanOptions) settle() time.Duration {
+	if o.Settle <= 0 {
+		return 5 * time.Millisecond
+	}
+
+	return o.Settle
+}
+
+// ScanStats are the statistics of a Scanner.
+type ScanStats struct {
+	// Probes is the number of probes sent.
+	Probes int
+
+	// Detections is the number of probes a card answered.
+	Detections int
+
+	// OnTime is the total time the reader was awake with the antenna on.
+	OnTime time.Duration
+
+	// Elapsed is the time since the first probe.
+	Elapsed time.Duration
+}
+
+// AverageOnTime returns the average time the reader was on for a probe.
+func (s ScanStats) AverageOnTime() time.Duration {
+	if s.Probes == 0 {
+		return 0
+	}
+
+	return s.OnTime / time.Duration(s.Probes)
+}
+
+// DutyCycle returns the fraction of the elapsed time the reader was on, between 0 and 1.
+func (s ScanStats) DutyCycle() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+
+	return float64(s.OnTime) / float64(s.Elapsed)
+}
+
+// Scanner waits for cards with a low duty cycle, for readers that run on a battery.
+// The reader is only woken up for a short REQA probe once per period, and the full
+// anti-collision and selection only run when a card answered it.
+//
+// The reader must not be used for anything else while Next runs. Between the calls, it is
+// asleep: to talk to a card, wake it up with PowerUp (with ScanOptions.PowerDown) or AntennaOn.
+// The card was reset when the RF field went off, so Select finds it again.
+type Scanner struct {
+	m    *MFRC522
+	opts ScanOptions
+
+	// started is the start of the first probe, and last the start of the latest probe.
+	started time.Time
+	last    time.Time
+
+	stats ScanStats
+}
+
+// NewScanner returns a Scanner, which uses the reader with the options.
+func (m *MFRC522) NewScanner(opts ScanOptions) *Scanner {
+	return &Scanner{m: m, opts: opts}
+}
+
+// Next probes for a card once per period, until a card answers or the context is canceled,
+// and returns the identification of the card. A card that stays in the RF field is returned
+// again by the next call, one period later.
+func (s *Scanner) Next(ctx context.Context) (CardInfo, error) {
+	for {
+		if !s.last.IsZero() {
+			select {
+			case <-ctx.Done():
+				return CardInfo{}, ctx.Err()
+			case <-time.After(time.Until(s.last.Add(s.opts.period()))):
+			}
+		}
+
+		s.last = time.Now()
+		if s.started.IsZero() {
+			s.started = s.last
+		}
+
+		card, ok, err := s.probe(ctx)
+		s.stats.Probes++
+		s.stats.OnTime += time.Since(s.last)
+		if err != nil {
+			return CardInfo{}, err
+		}
+		if ok {
+			s.stats.Detections++
+			return card, nil
+		}
+	}
+}
+
+// Stats returns the statistics of the probes sent so far.
+func (s *Scanner) Stats() ScanStats {
+	stats := s.stats
+	if !s.started.IsZero() {
+		stats.Elapsed = time.Since(s.started)
+	}
+
+	return stats
+}
+
+// probe wakes up the reader, sends REQA and selects the card if one answered,
+// and puts the reader to sleep again. It reports false if no card answered.
+func (s *Scanner) probe(ctx context.Context) (card CardInfo, ok bool, err error) {
+	if s.opts.PowerDown {
+		err = s.m.PowerUpContext(ctx)
+	} else {
+		err = s.m.AntennaOn()
+	}
+	if err != nil {
+		return CardInfo{}, false, err
+	}
+
+	defer func() {
+		if sleepErr := s.sleep(); err == nil {
+			err = sleepErr
+		}
+	}()
+
+	if err := s.m.StopCrypto(); err != nil {
+		return CardInfo{}, false, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return CardInfo{}, false, ctx.Err()
+	case <-time.After(s.opts.settle()):
+	}
+
+	atqa, ok, err := s.m.request(ctx, RequestACmd)
+	if err != nil || !ok {
+		return CardInfo{}, false, err
+	}
+
+	uuid, sak, err := s.m.cascade(ctx)
+	if err != nil {
+		return CardInfo{}, false, err
+	}
+
+	return CardInfo{UUID: uuid, ATQA: atqa, SAK: sak}, true, nil
+}
+
+// sleep turns off the antenna, and puts the reader into power-down if configured.
+func (s *Scanner) sleep() error {
+	if err := s.m.AntennaOff(); err != nil {
+		return err
+	}
+	if s.opts.PowerDown {
+		return s.m.PowerDown()
+	}
+
+	return nil
+}
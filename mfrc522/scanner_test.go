@@ -0,0 +1,107 @@
+package mfrc522_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+	"github.com/msthtrifork/gorfid/mfrc522/sim"
+)
+
+func TestScanner(t *testing.T) {
+	tests := []struct {
+		name      string
+		powerDown bool
+	}{
+		{"antenna off", false},
+		{"power-down", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+			m, _ := newReader(t, card)
+			period := 20 * time.Millisecond
+			s := m.NewScanner(mfrc522.ScanOptions{Period: period, Settle: time.Millisecond, PowerDown: tt.powerDown})
+
+			// A card that stays in the RF field is found again one period later
+			start := time.Now()
+			for range 2 {
+				found, err := s.Next(context.Background())
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				if !bytes.Equal(found.UUID, card.UID()) {
+					t.Errorf("Next().UUID = % x, want % x", found.UUID, card.UID())
+				}
+			}
+			if elapsed := time.Since(start); elapsed < period {
+				t.Errorf("Next() found the card twice within %v, want at least one period of %v", elapsed, period)
+			}
+
+			stats := s.Stats()
+			if stats.Probes != 2 || stats.Detections != 2 {
+				t.Errorf("Stats() = %d probes, %d detections, want 2 and 2", stats.Probes, stats.Detections)
+			}
+
+			// The reader is asleep between the calls
+			command, err := m.ReadRegister(mfrc522.CommandReg)
+			if err != nil {
+				t.Fatalf("ReadRegister() error = %v", err)
+			}
+			txControl, err := m.ReadRegister(mfrc522.TxControlReg)
+			if err != nil {
+				t.Fatalf("ReadRegister() error = %v", err)
+			}
+			if txControl&0x03 != 0 || (command&0x10 != 0) != tt.powerDown {
+				t.Errorf("after Next(), TxControlReg = %02x and CommandReg = %02x, want the antenna off "+
+					"and power-down %t", txControl, command, tt.powerDown)
+			}
+		})
+	}
+}
+
+func TestScannerNoCard(t *testing.T) {
+	m, c := newReader(t)
+	s := m.NewScanner(mfrc522.ScanOptions{Period: 10 * time.Millisecond, Settle: time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+	defer cancel()
+	if _, err := s.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Next() without a card error = %v, want DeadlineExceeded", err)
+	}
+
+	stats := s.Stats()
+	if stats.Probes < 3 || stats.Detections != 0 {
+		t.Errorf("Stats() = %d probes, %d detections, want at least 3 and 0", stats.Probes, stats.Detections)
+	}
+	if stats.AverageOnTime() <= 0 || stats.AverageOnTime() > 10*time.Millisecond {
+		t.Errorf("AverageOnTime() = %v, want between 0 and the period", stats.AverageOnTime())
+	}
+	if duty := stats.DutyCycle(); duty <= 0 || duty >= 1 {
+		t.Errorf("DutyCycle() = %v, want between 0 and 1", duty)
+	}
+
+	// A card that enters the RF field is found by one of the next probes
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	go func() {
+		time.Sleep(25 * time.Millisecond)
+		c.Add(card)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	found, err := s.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if !bytes.Equal(found.UUID, card.UID()) {
+		t.Errorf("Next().UUID = % x, want % x", found.UUID, card.UID())
+	}
+	if stats := s.Stats(); stats.Detections != 1 {
+		t.Errorf("Stats().Detections = %d, want 1", stats.Detections)
+	}
+}
@@ -0,0 +1,175 @@
+package mfrc522
+
+import (
+	"context"
+	"time"
+)
+
+// SelfTestResult is the result of the reader's digital self-test.
+type SelfTestResult struct {
+	// Version is the content of the VersionReg register.
+	Version byte
+
+	// Chip is the name of the chip with this version, or empty if there is no reference for it.
+	Chip string
+
+	// Data are the 64 bytes the self-test wrote to the FIFO buffer.
+	Data []byte
+
+	// Diffs are the bytes of Data that differ from the reference of the chip.
+	Diffs []SelfTestDiff
+}
+
+// SelfTestDiff is a byte of the self-test result that differs from the reference.
+type SelfTestDiff struct {
+	Offset int
+	Got    byte
+	Want   byte
+}
+
+// Known reports whether there is a reference for the version of the chip.
+func (r SelfTestResult) Known() bool {
+	return r.Chip != ""
+}
+
+// Passed reports whether the chip is known and its result matches the reference.
+func (r SelfTestResult) Passed() bool {
+	return r.Known() && len(r.Diffs) == 0
+}
+
+// selfTestReference is the expected self-test result of a chip version.
+type selfTestReference struct {
+	version byte
+	chip    string
+	data    [64]byte
+}
+
+// selfTestReferences are the known self-test results, taken from the datasheets and
+// from measurements of the clones found on cheap modules.
+var selfTestReferences = []selfTestReference{
+	{0x88, "FM17522", [64]byte{
+		0x00, 0xD6, 0x78, 0x8C, 0xE2, 0xAA, 0x0C, 0x18,
+		0x2A, 0xB8, 0x7A, 0x7F, 0xD3, 0x6A, 0xCF, 0x0B,
+		0xB1, 0x37, 0x63, 0x4B, 0x69, 0xAE, 0x91, 0xC7,
+		0xC3, 0x97, 0xAE, 0x77, 0xF4, 0x37, 0xD7, 0x9B,
+		0x7C, 0xF5, 0x3C, 0x11, 0x8F, 0x15, 0xC3, 0xD7,
+		0xC1, 0x5B, 0x00, 0x2A, 0xD0, 0x75, 0xDE, 0x9E,
+		0x51, 0x64, 0xAB, 0x3E, 0xE9, 0x15, 0xB5, 0xAB,
+		0x56, 0x9A, 0x98, 0x82, 0x26, 0xEA, 0x2A, 0x62,
+	}},
+	{0x90, "MFRC522 v0.0", [64]byte{
+		0x00, 0x87, 0x98, 0x0F, 0x49, 0xFF, 0x07, 0x19,
+		0xBF, 0x22, 0x30, 0x49, 0x59, 0x63, 0xAD, 0xCA,
+		0x7F, 0xE3, 0x4E, 0x03, 0x5C, 0x4E, 0x49, 0x50,
+		0x47, 0x9A, 0x37, 0x61, 0xE7, 0xE2, 0xC6, 0x2E,
+		0x75, 0x5A, 0xED, 0x04, 0x3D, 0x02, 0x4B, 0x78,
+		0x32, 0xFF, 0x58, 0x3B, 0x7C, 0xE9, 0x00, 0x94,
+		0xB4, 0x4A, 0x59, 0x5B, 0xFD, 0xC9, 0x29, 0xDF,
+		0x35, 0x96, 0x98, 0x9E, 0x4F, 0x30, 0x32, 0x8D,
+	}},
+	{0x91, "MFRC522 v1.0", [64]byte{
+		0x00, 0xC6, 0x37, 0xD5, 0x32, 0xB7, 0x57, 0x5C,
+		0xC2, 0xD8, 0x7C, 0x4D, 0xD9, 0x70, 0xC7, 0x73,
+		0x10, 0xE6, 0xD2, 0xAA, 0x5E, 0xA1, 0x3E, 0x5A,
+		0x14, 0xAF, 0x30, 0x61, 0xC9, 0x70, 0xDB, 0x2E,
+		0x64, 0x22, 0x72, 0xB5, 0xBD, 0x65, 0xF4, 0xEC,
+		0x22, 0xBC, 0xD3, 0x72, 0x35, 0xCD, 0xAA, 0x41,
+		0x1F, 0xA7, 0xF3, 0x53, 0x14, 0xDE, 0x7E, 0x02,
+		0xD9, 0x0F, 0xB5, 0x5E, 0x25, 0x1D, 0x29, 0x79,
+	}},
+	{0x92, "MFRC522 v2.0", [64]byte{
+		0x00, 0xEB, 0x66, 0xBA, 0x57, 0xBF, 0x23, 0x95,
+		0xD0, 0xE3, 0x0D, 0x3D, 0x27, 0x89, 0x5C, 0xDE,
+		0x9D, 0x3B, 0xA7, 0x00, 0x21, 0x5B, 0x89, 0x82,
+		0x51, 0x3A, 0xEB, 0x02, 0x0C, 0xA5, 0x00, 0x49,
+		0x7C, 0x84, 0x4D, 0xB3, 0xCC, 0xD2, 0x1B, 0x81,
+		0x5D, 0x48, 0x76, 0xD5, 0x71, 0x61, 0x21, 0xA9,
+		0x86, 0x96, 0x83, 0x38, 0xCF, 0x9D, 0x5B, 0x6D,
+		0xDC, 0x15, 0xBA, 0x3E, 0x7D, 0x95, 0x3B, 0x2F,
+	}},
+}
+
+// SelfTest performs the digital self-test of the MFRC522 reader
+// (specified in Chapter 16.1.1 of the MFRC55 datasheet) and compares its result with the
+// reference for the reader's version. The reader is reset for the test and initialized
+// again afterwards.
+func (m *MFRC522) SelfTest() (SelfTestResult, error) {
+	return m.SelfTestContext(context.Background())
+}
+
+// SelfTestContext is like SelfTest, but stops when the context is canceled or its deadline expires.
+func (m *MFRC522) SelfTestContext(ctx context.Context) (SelfTestResult, error) {
+	data, err := m.selfTest(ctx)
+
+	// The test leaves the reader in the self-test mode, which only a reset ends
+	if resetErr := m.Reset(); err == nil {
+		err = resetErr
+	}
+	if setupErr := m.setup(); err == nil {
+		err = setupErr
+	}
+	if err != nil {
+		return SelfTestResult{}, err
+	}
+
+	ver, err := m.Version()
+	if err != nil {
+		return SelfTestResult{}, err
+	}
+
+	result := SelfTestResult{Version: ver, Data: data}
+	for _, ref := range selfTestReferences {
+		if ref.version != ver {
+			continue
+		}
+
+		result.Chip = ref.chip
+		for i, want := range ref.data {
+			if got := data[i]; got != want {
+				result.Diffs = append(result.Diffs, SelfTestDiff{Offset: i, Got: got, Want: want})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// selfTest runs the self-test and returns the 64 bytes it wrote to the FIFO buffer.
+func (m *MFRC522) selfTest(ctx context.Context) ([]byte, error) {
+	if err := m.Reset(); err != nil {
+		return nil, err
+	}
+
+	// The content of the internal buffer is part of the test, so it is cleared first
+	if err := m.WriteRegister(FIFOLevelReg, 0x80); err != nil {
+		return nil, err
+	}
+	if err := m.WriteRegisterBytes(FIFODataReg, make([]byte, 25)); err != nil {
+		return nil, err
+	}
+	if err := m.WriteRegister(CommandReg, MemCmd); err != nil {
+		return nil, err
+	}
+
+	// With a water level of 0, HiAlert signals a full FIFO buffer, which ends the test
+	if err := m.WriteSequence([]WriteCommand{
+		{AutoTestReg, 0x09},
+		{WaterLevelReg, 0x00},
+		{ComIEnReg, 0x80 | 0x08},
+		{ComIrqReg, 0x08},
+		{FIFODataReg, 0x00},
+		{CommandReg, CalcCRCCmd},
+	}); err != nil {
+		return nil, err
+	}
+
+	if _, err := m.waitForIRQ(ctx, ComIrqReg, 0x08, 100*time.Millisecond, m.commandPollInterval); err != nil {
+		return nil, err
+	}
+
+	if err := m.WriteRegister(CommandReg, IdleCmd); err != nil {
+		return nil, err
+	}
+
+	return m.ReadRegisterBytes(FIFODataReg, 64)
+}
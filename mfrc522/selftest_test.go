@@ -0,0 +1,116 @@
+package mfrc522_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+	"github.com/msthtrifork/gorfid/mfrc522/sim"
+)
+
+// chipBus is a simulated chip that reports another version, and can change a byte of the
+// self-test result, to check the results of other chips.
+type chipBus struct {
+	*sim.Chip
+
+	version byte
+
+	// flip is XORed into the byte at offset when the 64 bytes of the self-test are read.
+	offset int
+	flip   byte
+}
+
+// ReadRegisterBytes reads from the simulated chip, and replaces the version and the self-test result.
+func (b *chipBus) ReadRegisterBytes(reg mfrc522.Register, readLen int) ([]byte, error) {
+	val, err := b.Chip.ReadRegisterBytes(reg, readLen)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case reg == mfrc522.VersionReg:
+		val[0] = b.version
+	case reg == mfrc522.FIFODataReg && readLen == 64:
+		val[b.offset] ^= b.flip
+	}
+
+	return val, nil
+}
+
+func TestSelfTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		version byte
+		offset  int
+		flip    byte
+		chip    string
+		diffs   int
+		passed  bool
+	}{
+		{"v2.0", 0x92, 0, 0, "MFRC522 v2.0", 0, true},
+		{"v2.0 with a wrong byte", 0x92, 5, 0x01, "MFRC522 v2.0", 1, false},
+		{"v1.0", 0x91, 0, 0, "MFRC522 v1.0", 63, false},
+		{"v0.0", 0x90, 0, 0, "MFRC522 v0.0", 63, false},
+		{"FM17522", 0x88, 0, 0, "FM17522", 62, false},
+		{"unknown", 0x12, 0, 0, "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := sim.NewChip()
+			bus := &chipBus{Chip: c, version: tt.version, offset: tt.offset, flip: tt.flip}
+			m, err := mfrc522.New(bus, c.RST(), c.IRQ(), 200*time.Millisecond)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			result, err := m.SelfTest()
+			if err != nil {
+				t.Fatalf("SelfTest() error = %v", err)
+			}
+
+			if result.Version != tt.version || result.Chip != tt.chip || len(result.Data) != 64 {
+				t.Errorf("SelfTest() = version %02x, chip %q, %d bytes, want %02x, %q, 64 bytes",
+					result.Version, result.Chip, len(result.Data), tt.version, tt.chip)
+			}
+			if len(result.Diffs) != tt.diffs {
+				t.Errorf("SelfTest() = %d diffs, want %d", len(result.Diffs), tt.diffs)
+			}
+			if result.Known() != (tt.chip != "") || result.Passed() != tt.passed {
+				t.Errorf("Known() = %t, Passed() = %t, want %t, %t",
+					result.Known(), result.Passed(), tt.chip != "", tt.passed)
+			}
+
+			for _, diff := range result.Diffs {
+				if diff.Got != result.Data[diff.Offset] || diff.Got == diff.Want {
+					t.Errorf("diff at offset %d = %02x, want %02x, but the result is %02x",
+						diff.Offset, diff.Got, diff.Want, result.Data[diff.Offset])
+				}
+			}
+			if tt.flip != 0 && (len(result.Diffs) != 1 || result.Diffs[0].Offset != tt.offset) {
+				t.Errorf("SelfTest().Diffs = %+v, want the byte at offset %d", result.Diffs, tt.offset)
+			}
+		})
+	}
+}
+
+func TestSelfTestRestoresReader(t *testing.T) {
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	c := sim.NewChip()
+	c.Add(card)
+	m, err := mfrc522.New(c, c.RST(), c.IRQ(), 200*time.Millisecond, mfrc522.WithAntennaGain(mfrc522.Gain48dB))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := m.SelfTest(); err != nil {
+		t.Fatalf("SelfTest() error = %v", err)
+	}
+
+	if gain, err := m.AntennaGain(); err != nil || gain != mfrc522.Gain48dB {
+		t.Errorf("AntennaGain() after SelfTest() = %v, %v, want %v", gain, err, mfrc522.Gain48dB)
+	}
+	if _, err := m.ReadCard(); err != nil {
+		t.Errorf("ReadCard() after SelfTest() error = %v", err)
+	}
+}
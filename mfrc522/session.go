@@ -0,0 +1,291 @@
+package mfrc522
+
+import (
+	"context"
+	"errors"
+)
+
+// Session is a card that was selected by the reader. It allows reading and writing
+// multiple blocks without selecting the card again for each of them, and remembers the
+// authenticated sector, so blocks in the same sector don't need another authentication.
+//
+// A session must be ended with Halt or Close, which stop the crypto unit of the reader.
+//
+// The methods with a context stop when the context is canceled or its deadline expires.
+// The card is then in an unknown state, so an operation that was interrupted while talking
+// to the card usually loses it and closes the session.
+type Session struct {
+	m    *MFRC522
+	card CardInfo
+
+	// authenticated is true if sector was authenticated with authMode and key.
+	authenticated bool
+	sector        byte
+	authMode      byte
+	key           []byte
+
+	closed bool
+}
+
+// Select waits for a card, selects it and returns a session for it.
+func (m *MFRC522) Select() (*Session, error) {
+	return m.SelectContext(context.Background())
+}
+
+// SelectContext is like Select, but stops waiting for a card when the context is canceled
+// or its deadline expires.
+func (m *MFRC522) SelectContext(ctx context.Context) (*Session, error) {
+	// A previous session might have been left in an authenticated state
+	if err := m.StopCrypto(); err != nil {
+		return nil, err
+	}
+
+	card, err := m.selectCard(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{m: m, card: card}, nil
+}
+
+// Card returns the identification of the selected card.
+func (s *Session) Card() CardInfo {
+	return s.card
+}
+
+// UUID returns the UUID of the selected card.
+func (s *Session) UUID() []byte {
+	return s.card.UUID
+}
+
+// Sector returns the currently authenticated sector, or false if no sector is authenticated.
+func (s *Session) Sector() (byte, bool) {
+	return s.sector, s.authenticated
+}
+
+// Authenticate authenticates the sector of the block address with the key.
+// Nothing is sent to the card if the sector is already authenticated with the same key.
+//
+// A card that fails the authentication stops answering, so the session selects it again
+// before returning the error. This allows trying another key in the same session.
+func (s *Session) Authenticate(authMode, addr byte, key []byte) error {
+	return s.AuthenticateContext(context.Background(), authMode, addr, key)
+}
+
+// AuthenticateContext is like Authenticate, but stops when the context is canceled or its deadline expires.
+func (s *Session) AuthenticateContext(ctx context.Context, authMode, addr byte, key []byte) error {
+	if s.closed {
+		return errors.New("session is closed")
+	}
+
+	sector := BlockSector(addr)
+	if s.authenticated && s.sector == sector && s.authMode == authMode && string(s.key) == string(key) {
+		return nil
+	}
+
+	s.authenticated = false
+
+	auth, err := s.m.authenticate(ctx, authMode, addr, key, s.card.UUID)
+	if err == nil && auth == AuthOk {
+		s.authenticated = true
+		s.sector = sector
+		s.authMode = authMode
+		s.key = append(s.key[:0], key...)
+
+		return nil
+	}
+
+	if selErr := s.reselect(ctx); selErr != nil {
+		if err == nil {
+			err = ErrAuth
+		}
+
+		return reselectError{err: err, selErr: selErr}
+	}
+	if err != nil {
+		return err
+	}
+
+	return ErrAuth
+}
+
+// ReadBlock reads the block at the address, whose sector must be authenticated.
+//
+// A card that refuses to read the block (e.g. because of its access bits) stops answering,
+// so the session selects it again before returning the error. The sector then needs to be
+// authenticated again.
+func (s *Session) ReadBlock(addr byte) ([]byte, error) {
+	return s.ReadBlockContext(context.Background(), addr)
+}
+
+// ReadBlockContext is like ReadBlock, but stops when the context is canceled or its deadline expires.
+func (s *Session) ReadBlockContext(ctx context.Context, addr byte) ([]byte, error) {
+	if err := s.checkSector(addr); err != nil {
+		return nil, err
+	}
+
+	data, err := s.m.readTag(ctx, addr)
+	if err != nil {
+		s.authenticated = false
+		if selErr := s.reselect(ctx); selErr != nil {
+			return nil, reselectError{err: err, selErr: selErr}
+		}
+
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// WriteBlock writes data to the block at the address, whose sector must be authenticated.
+//
+// Like ReadBlock, the session selects the card again if it refuses the write.
+func (s *Session) WriteBlock(addr byte, data []byte) error {
+	return s.WriteBlockContext(context.Background(), addr, data)
+}
+
+// WriteBlockContext is like WriteBlock, but stops when the context is canceled or its deadline expires.
+func (s *Session) WriteBlockContext(ctx context.Context, addr byte, data []byte) error {
+	if err := s.checkSector(addr); err != nil {
+		return err
+	}
+
+	if err := s.m.writeTag(ctx, addr, data); err != nil {
+		s.authenticated = false
+		if selErr := s.reselect(ctx); selErr != nil {
+			return reselectError{err: err, selErr: selErr}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// VerifyBlock reads the block at the address back and returns ErrMismatch if it differs from data.
+// The card never returns key A of a sector trailer, and only returns key B if it is readable,
+// so only the access bits and a returned key B are compared for trailers.
+func (s *Session) VerifyBlock(addr byte, data []byte) error {
+	return s.VerifyBlockContext(context.Background(), addr, data)
+}
+
+// VerifyBlockContext is like VerifyBlock, but stops when the context is canceled or its deadline expires.
+func (s *Session) VerifyBlockContext(ctx context.Context, addr byte, data []byte) error {
+	read, err := s.ReadBlockContext(ctx, addr)
+	if err != nil {
+		return err
+	}
+
+	equal := string(data) == string(read)
+	if isTrailer(addr) && len(data) == 16 {
+		equal = string(data[6:10]) == string(read[6:10]) &&
+			(string(data[10:]) == string(read[10:]) || string(read[10:]) == string(make([]byte, 6)))
+	}
+	if !equal {
+		return ErrMismatch{Addr: addr}
+	}
+
+	return nil
+}
+
+// Closed reports whether the session was ended, either by Halt or Close,
+// or because the card was lost.
+func (s *Session) Closed() bool {
+	return s.closed
+}
+
+// Halt puts the card into the HALT state and ends the session.
+// The card doesn't answer again until it is woken up or leaves the RF field.
+func (s *Session) Halt() error {
+	return s.HaltContext(context.Background())
+}
+
+// HaltContext is like Halt, but stops when the context is canceled or its deadline expires.
+// The session is ended in any case.
+func (s *Session) HaltContext(ctx context.Context) error {
+	if s.closed {
+		return nil
+	}
+
+	err := s.m.halt(ctx)
+	if closeErr := s.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Close ends the session and stops the crypto unit of the reader.
+// The card stays selected until the next request.
+func (s *Session) Close() error {
+	s.closed = true
+	s.authenticated = false
+
+	return s.m.StopCrypto()
+}
+
+// checkSector returns an error if the sector of the block address is not authenticated.
+func (s *Session) checkSector(addr byte) error {
+	if s.closed {
+		return errors.New("session is closed")
+	}
+	if !s.authenticated || s.sector != BlockSector(addr) {
+		return errors.New("sector is not authenticated")
+	}
+
+	return nil
+}
+
+// reselect wakes up and selects the session's card again, after it left the selected state.
+// If that fails, the card is lost and the session is closed.
+func (s *Session) reselect(ctx context.Context) error {
+	if err := s.reselectCard(ctx); err != nil {
+		_ = s.Close()
+		return err
+	}
+
+	return nil
+}
+
+// reselectCard sends WUPA and runs the anti-collision and selection, expecting the session's card.
+func (s *Session) reselectCard(ctx context.Context) error {
+	if err := s.stopCrypto(); err != nil {
+		return err
+	}
+
+	// A card that is still selected ignores WUPA
+	_ = s.m.halt(ctx)
+
+	if _, ok, err := s.m.request(ctx, WakeUpACmd); err != nil || !ok {
+		if err == nil {
+			err = ErrNoCard
+		}
+
+		return err
+	}
+
+	uuid, _, err := s.m.cascade(ctx)
+	if err != nil {
+		return err
+	}
+	if string(uuid) != string(s.card.UUID) {
+		return wrap("a different card was selected", ErrNoCard)
+	}
+
+	return nil
+}
+
+// stopCrypto stops the crypto unit of the reader, which ends the authentication.
+func (s *Session) stopCrypto() error {
+	s.authenticated = false
+
+	return s.m.StopCrypto()
+}
+
+// setUUID updates the UUID of the session after block 0 of a magic card was written,
+// so the card can be authenticated and selected again.
+func (s *Session) setUUID(block0 []byte) {
+	if n := len(s.card.UUID); n == 4 || n == 7 {
+		s.card.UUID = append([]byte(nil), block0[:n]...)
+	}
+}
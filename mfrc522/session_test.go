@@ -0,0 +1,250 @@
+package mfrc522_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+	"github.com/msthtrifork/gorfid/mfrc522/sim"
+)
+
+// Access bits for the tests. With noReadAccess, the data blocks can neither be read nor written,
+// and with secretKeyB, key B can't be read, so it can be used for authentication.
+var (
+	noReadAccess = []byte{0x88, 0x70, 0xF7, 0x69}
+	secretKeyB   = []byte{0x7F, 0x07, 0x88, 0x69}
+)
+
+// selectCard selects the only card in the reader's RF field.
+func selectCard(t *testing.T, m *mfrc522.MFRC522) *mfrc522.Session {
+	t.Helper()
+
+	s, err := m.Select()
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+func TestAuthenticate(t *testing.T) {
+	keyA := []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}
+	keyB := []byte{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5}
+
+	tests := []struct {
+		name     string
+		authMode byte
+		key      []byte
+		err      error
+	}{
+		{"key A", mfrc522.AuthKeyACmd, keyA, nil},
+		{"key B", mfrc522.AuthKeyBCmd, keyB, nil},
+		{"wrong key A", mfrc522.AuthKeyACmd, keyB, mfrc522.ErrAuth},
+		{"default key", mfrc522.AuthKeyACmd, sim.DefaultKey, mfrc522.ErrAuth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+			card.SetTrailer(1, keyA, secretKeyB, keyB)
+			m, _ := newReader(t, card)
+			s := selectCard(t, m)
+
+			err := s.Authenticate(tt.authMode, 4, tt.key)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.err)
+			}
+
+			sector, ok := s.Sector()
+			if ok != (tt.err == nil) || ok && sector != 1 {
+				t.Errorf("Sector() = %d, %t after Authenticate() error = %v", sector, ok, err)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAfterWrongKey(t *testing.T) {
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	card.SetBlock(4, [16]byte{0x01, 0x02, 0x03})
+	m, _ := newReader(t, card)
+	s := selectCard(t, m)
+
+	// The card leaves the selected state after a failed authentication, so the session selects it again
+	if err := s.Authenticate(mfrc522.AuthKeyACmd, 4, []byte{1, 2, 3, 4, 5, 6}); !errors.Is(err, mfrc522.ErrAuth) {
+		t.Fatalf("Authenticate() with a wrong key error = %v, want ErrAuth", err)
+	}
+	if s.Closed() {
+		t.Fatal("Closed() = true after a wrong key, want the card selected again")
+	}
+
+	if err := s.Authenticate(mfrc522.AuthKeyACmd, 4, sim.DefaultKey); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	data, err := s.ReadBlock(4)
+	if err != nil {
+		t.Fatalf("ReadBlock() error = %v", err)
+	}
+	if want := card.Block(4); !bytes.Equal(data, want[:]) {
+		t.Errorf("ReadBlock() = % x, want % x", data, want)
+	}
+}
+
+func TestReadWriteBlock(t *testing.T) {
+	card := newCard(t, sim.Classic4K, 0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06)
+	m, _ := newReader(t, card)
+	s := selectCard(t, m)
+
+	data := []byte("0123456789abcdef")
+	for _, addr := range []byte{1, 6, 130, 254} {
+		if err := s.Authenticate(mfrc522.AuthKeyACmd, addr, sim.DefaultKey); err != nil {
+			t.Fatalf("Authenticate(%d) error = %v", addr, err)
+		}
+		if err := s.WriteBlock(addr, data); err != nil {
+			t.Fatalf("WriteBlock(%d) error = %v", addr, err)
+		}
+
+		got, err := s.ReadBlock(addr)
+		if err != nil {
+			t.Fatalf("ReadBlock(%d) error = %v", addr, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("ReadBlock(%d) = % x, want % x", addr, got, data)
+		}
+		if block := card.Block(int(addr)); !bytes.Equal(block[:], data) {
+			t.Errorf("card block %d = % x, want % x", addr, block, data)
+		}
+	}
+}
+
+func TestVerifyBlock(t *testing.T) {
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	card.SetBlock(5, [16]byte{0x01, 0x02, 0x03})
+	m, _ := newReader(t, card)
+	s := selectCard(t, m)
+
+	if err := s.Authenticate(mfrc522.AuthKeyACmd, 5, sim.DefaultKey); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	block := card.Block(5)
+	if err := s.VerifyBlock(5, block[:]); err != nil {
+		t.Errorf("VerifyBlock() error = %v", err)
+	}
+
+	var mismatch mfrc522.ErrMismatch
+	if err := s.VerifyBlock(5, make([]byte, 16)); !errors.As(err, &mismatch) || mismatch.Addr != 5 {
+		t.Errorf("VerifyBlock() with other data error = %v, want ErrMismatch for block 5", err)
+	}
+
+	// Key A is never returned, so only the access bits and key B are compared for trailers
+	trailer := append(make([]byte, 6), sim.TransportAccessBits...)
+	trailer = append(trailer, sim.DefaultKey...)
+	if err := s.VerifyBlock(7, trailer); err != nil {
+		t.Errorf("VerifyBlock() of the trailer error = %v", err)
+	}
+}
+
+func TestReadBlockNAK(t *testing.T) {
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	card.SetTrailer(1, sim.DefaultKey, noReadAccess, sim.DefaultKey)
+	m, _ := newReader(t, card)
+	s := selectCard(t, m)
+
+	if err := s.Authenticate(mfrc522.AuthKeyACmd, 4, sim.DefaultKey); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	var nak mfrc522.ErrNAK
+	if _, err := s.ReadBlock(4); !errors.As(err, &nak) {
+		t.Fatalf("ReadBlock() error = %v, want ErrNAK", err)
+	}
+	if err := s.WriteBlock(4, make([]byte, 16)); err == nil {
+		t.Fatal("WriteBlock() after the NAK succeeded, want an unauthenticated sector")
+	}
+
+	// The card was selected again after the NAK, so other sectors can still be read
+	if s.Closed() {
+		t.Fatal("Closed() = true after a NAK, want the card selected again")
+	}
+	if err := s.Authenticate(mfrc522.AuthKeyACmd, 8, sim.DefaultKey); err != nil {
+		t.Fatalf("Authenticate() after the NAK error = %v", err)
+	}
+	if _, err := s.ReadBlock(8); err != nil {
+		t.Errorf("ReadBlock() after the NAK error = %v", err)
+	}
+}
+
+func TestWriteBlockNAK(t *testing.T) {
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	m, _ := newReader(t, card)
+	s := selectCard(t, m)
+
+	if err := s.Authenticate(mfrc522.AuthKeyACmd, 0, sim.DefaultKey); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	// Block 0 of a genuine card is read-only
+	block0 := card.Block(0)
+	var nak mfrc522.ErrNAK
+	if err := s.WriteBlock(0, make([]byte, 16)); !errors.As(err, &nak) {
+		t.Fatalf("WriteBlock(0) error = %v, want ErrNAK", err)
+	}
+	if got := card.Block(0); got != block0 {
+		t.Errorf("block 0 = % x after the refused write, want % x", got, block0)
+	}
+}
+
+func TestSessionNotAuthenticated(t *testing.T) {
+	m, _ := newReader(t, newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF))
+	s := selectCard(t, m)
+
+	if _, err := s.ReadBlock(4); err == nil {
+		t.Error("ReadBlock() without authentication succeeded")
+	}
+
+	if err := s.Authenticate(mfrc522.AuthKeyACmd, 4, sim.DefaultKey); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if _, err := s.ReadBlock(8); err == nil {
+		t.Error("ReadBlock() of another sector succeeded")
+	}
+}
+
+func TestSessionLostCard(t *testing.T) {
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	m, c := newReader(t, card)
+	s := selectCard(t, m)
+
+	if err := s.Authenticate(mfrc522.AuthKeyACmd, 4, sim.DefaultKey); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	c.Remove(card)
+	if _, err := s.ReadBlock(4); err == nil {
+		t.Fatal("ReadBlock() of a removed card succeeded")
+	}
+	if !s.Closed() {
+		t.Error("Closed() = false after the card was removed")
+	}
+}
+
+func TestHalt(t *testing.T) {
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	m, _ := newReader(t, card)
+	s := selectCard(t, m)
+
+	if err := s.Halt(); err != nil {
+		t.Fatalf("Halt() error = %v", err)
+	}
+	if !s.Closed() {
+		t.Error("Closed() = false after Halt()")
+	}
+
+	// Select wakes up halted cards
+	s = selectCard(t, m)
+	if !bytes.Equal(s.UUID(), card.UID()) {
+		t.Errorf("UUID() = % x, want % x", s.UUID(), card.UID())
+	}
+}
@@ -0,0 +1,757 @@
+// Package sim implements a software model of the MFRC522 reader and the cards in its RF field,
+// so the mfrc522 package can be used without the hardware.
+package sim
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/msthtrifork/gorfid/crypto1"
+	"github.com/msthtrifork/gorfid/mfrc522"
+)
+
+// ComIrqReg bits
+const (
+	txIRq      = 0x40
+	rxIRq      = 0x20
+	idleIRq    = 0x10
+	hiAlertIRq = 0x08
+	loAlertIRq = 0x04
+	errIRq     = 0x02
+	timerIRq   = 0x01
+)
+
+// DivIrqReg bits
+const (
+	crcIRq = 0x04
+)
+
+// ErrorReg bits
+const (
+	bufferOvfl  = 0x10
+	collErr     = 0x08
+	crcErr      = 0x04
+	parityErr   = 0x02
+	protocolErr = 0x01
+)
+
+// Status2Reg bits
+const (
+	mfCrypto1On = 0x08
+)
+
+// fifoSize is the size of the FIFO buffer.
+const fifoSize = 64
+
+// resetValues are the register values after a reset (Chapter 9.3 of the MFRC55 datasheet).
+var resetValues = map[mfrc522.Register]byte{
+	mfrc522.CommandReg:       0x20,
+	mfrc522.ComIEnReg:        0x80,
+	mfrc522.ComIrqReg:        0x14,
+	mfrc522.Status1Reg:       0x21,
+	mfrc522.WaterLevelReg:    0x08,
+	mfrc522.ControlReg:       0x10,
+	mfrc522.CollReg:          0xA0,
+	mfrc522.ModeReg:          0x3F,
+	mfrc522.TxControlReg:     0x80,
+	mfrc522.TxSelReg:         0x10,
+	mfrc522.RxSelReg:         0x84,
+	mfrc522.RxThresholdReg:   0x84,
+	mfrc522.DemodReg:         0x4D,
+	mfrc522.MfTxReg:          0x62,
+	mfrc522.SerialSpeedReg:   0xEB,
+	mfrc522.CRCResultHighReg: 0xFF,
+	mfrc522.CRCResultLowReg:  0xFF,
+	mfrc522.ModWidthReg:      0x26,
+	mfrc522.RFCfgReg:         0x48,
+	mfrc522.GsNReg:           0x88,
+	mfrc522.CWGsPReg:         0x20,
+	mfrc522.ModGsPReg:        0x20,
+	mfrc522.TestPinEnReg:     0x80,
+	mfrc522.AutoTestReg:      0x40,
+}
+
+// Chip is a register-level model of the MFRC522 reader.
+// It implements mfrc522.Bus, so it can be passed to mfrc522.New instead of a real bus.
+// After a successful MFAuthent command, all frames are encrypted with Crypto1, including the parity bits.
+//
+// Commands are executed instantly, so the internal timer expires as soon as
+// a card doesn't answer, regardless of its configuration.
+type Chip struct {
+	mu sync.Mutex
+
+	// regs holds the values of the registers.
+	regs [64]byte
+
+	// fifo is the content of the FIFO buffer.
+	fifo []byte
+
+	// buffer is the internal 25-byte buffer used by the Mem command.
+	buffer [25]byte
+
+	// crc is the current value of the CRC coprocessor.
+	crc uint16
+
+	// cipher is the Crypto1 session with the authenticated card, or nil.
+	cipher *crypto1.Cipher
+
+	// version is the value of the VersionReg register.
+	version byte
+
+	// targets are the cards in the RF field.
+	targets []Target
+
+	// field is whether the RF field was on after the last operation.
+	field bool
+
+	// rst is the level of the reset pin.
+	rst bool
+
+	// irq is the level of the interrupt pin after the last operation.
+	irq bool
+
+	// irqFunc is called whenever the level of the interrupt pin changes.
+	irqFunc func()
+}
+
+var _ mfrc522.Bus = (*Chip)(nil)
+
+// NewChip creates a new reader with an empty RF field.
+func NewChip() *Chip {
+	c := &Chip{version: 0x92, rst: true}
+	c.reset()
+	c.irq = c.irqLevel()
+
+	return c
+}
+
+// Add places the card in the RF field of the reader.
+func (c *Chip) Add(t Target) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.targets = append(c.targets, t)
+}
+
+// Remove takes the card out of the RF field of the reader.
+func (c *Chip) Remove(t Target) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, target := range c.targets {
+		if target == t {
+			c.targets = append(c.targets[:i], c.targets[i+1:]...)
+			t.Reset()
+
+			return
+		}
+	}
+}
+
+// ReadRegisterBytes reads readLen bytes from the specified register.
+func (c *Chip) ReadRegisterBytes(reg mfrc522.Register, readLen int) ([]byte, error) {
+	if int(reg) >= len(c.regs) {
+		return nil, errors.New("invalid register")
+	}
+
+	c.mu.Lock()
+	res := make([]byte, readLen)
+	for i := range res {
+		res[i] = c.read(reg)
+	}
+	notify := c.update()
+	c.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+
+	return res, nil
+}
+
+// WriteRegisterBytes writes the bytes to the specified register.
+func (c *Chip) WriteRegisterBytes(reg mfrc522.Register, val []byte) error {
+	if int(reg) >= len(c.regs) {
+		return errors.New("invalid register")
+	}
+
+	c.mu.Lock()
+	for _, v := range val {
+		c.write(reg, v)
+	}
+	notify := c.update()
+	c.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+
+	return nil
+}
+
+// IRQ returns the interrupt pin of the reader.
+func (c *Chip) IRQ() mfrc522.Pin {
+	return irqPin{c}
+}
+
+// RST returns the reset pin of the reader.
+func (c *Chip) RST() mfrc522.Pin {
+	return rstPin{c}
+}
+
+// read returns the value of the register, as seen by the host.
+func (c *Chip) read(reg mfrc522.Register) byte {
+	switch reg {
+	case mfrc522.FIFODataReg:
+		if len(c.fifo) == 0 {
+			return 0
+		}
+
+		val := c.fifo[0]
+		c.fifo = c.fifo[1:]
+		c.updateFIFO()
+
+		return val
+	case mfrc522.FIFOLevelReg:
+		return byte(len(c.fifo))
+	case mfrc522.Status1Reg:
+		return c.status1()
+	case mfrc522.VersionReg:
+		return c.version
+	}
+
+	return c.regs[reg]
+}
+
+// write writes the value to the register, as done by the host.
+func (c *Chip) write(reg mfrc522.Register, val byte) {
+	switch reg {
+	case mfrc522.CommandReg:
+		c.regs[reg] = c.regs[reg]&0x0F | val&0x30
+		if val&0x0F != mfrc522.NoCmdChangeCmd {
+			c.execute(val & 0x0F)
+		}
+	case mfrc522.ComIrqReg, mfrc522.DivIrqReg:
+		// Bit 7 selects whether the marked bits are set or cleared
+		if val&0x80 != 0 {
+			c.regs[reg] |= val & 0x7F
+		} else {
+			c.regs[reg] &^= val & 0x7F
+		}
+	case mfrc522.Status2Reg:
+		// MFCrypto1On can only be cleared by the host
+		c.regs[reg] = val&0xC0 | c.regs[reg]&val&mfCrypto1On | c.regs[reg]&0x07
+		if c.regs[reg]&mfCrypto1On == 0 {
+			c.cipher = nil
+		}
+	case mfrc522.FIFODataReg:
+		if len(c.fifo) == fifoSize {
+			c.setError(bufferOvfl)
+			return
+		}
+
+		c.fifo = append(c.fifo, val)
+		c.updateFIFO()
+		if c.command() == mfrc522.CalcCRCCmd {
+			c.calcCRC()
+		}
+	case mfrc522.FIFOLevelReg:
+		if val&0x80 != 0 {
+			c.fifo = c.fifo[:0]
+			c.regs[mfrc522.ErrorReg] &^= bufferOvfl
+			c.updateFIFO()
+		}
+	case mfrc522.ControlReg:
+		if val&0x40 != 0 {
+			c.regs[mfrc522.ComIrqReg] |= timerIRq
+		}
+	case mfrc522.BitFramingReg:
+		c.regs[reg] = val
+		if val&0x80 != 0 && c.command() == mfrc522.TransceiveCmd {
+			c.transceive()
+		}
+	case mfrc522.CollReg:
+		c.regs[reg] = c.regs[reg]&0x7F | val&0x80
+	case mfrc522.ErrorReg, mfrc522.Status1Reg, mfrc522.CRCResultHighReg, mfrc522.CRCResultLowReg,
+		mfrc522.TCounterValHighReg, mfrc522.TCounterValLowReg, mfrc522.VersionReg:
+		// Read-only registers
+	default:
+		c.regs[reg] = val
+	}
+}
+
+// command returns the currently executed command.
+func (c *Chip) command() mfrc522.RegisterCommand {
+	return c.regs[mfrc522.CommandReg] & 0x0F
+}
+
+// execute starts the command (Chapter 10.3 of the MFRC55 datasheet).
+func (c *Chip) execute(cmd mfrc522.RegisterCommand) {
+	c.regs[mfrc522.CommandReg] = c.regs[mfrc522.CommandReg]&0xF0 | cmd
+
+	switch cmd {
+	case mfrc522.MemCmd:
+		if len(c.fifo) > 0 {
+			n := copy(c.buffer[:], c.fifo)
+			c.fifo = c.fifo[n:]
+		} else {
+			c.fifo = append(c.fifo, c.buffer[:]...)
+		}
+		c.updateFIFO()
+		c.terminate()
+	case mfrc522.GenerateRandomIDCmd:
+		_, _ = rand.Read(c.buffer[:10])
+		c.terminate()
+	case mfrc522.CalcCRCCmd:
+		if c.regs[mfrc522.AutoTestReg]&0x0F == 0x09 {
+			c.selfTest()
+			return
+		}
+
+		c.crc = crcPreset(c.regs[mfrc522.ModeReg])
+		c.calcCRC()
+	case mfrc522.TransmitCmd:
+		c.regs[mfrc522.ErrorReg] &= bufferOvfl
+		c.transmit()
+		c.terminate()
+	case mfrc522.MFAuthentCmd:
+		c.authenticate()
+	case mfrc522.SoftResetCmd:
+		c.reset()
+	}
+}
+
+// terminate ends the current command, as if it terminated by itself.
+func (c *Chip) terminate() {
+	c.regs[mfrc522.CommandReg] &= 0xF0
+	c.regs[mfrc522.ComIrqReg] |= idleIRq
+}
+
+// reset sets all registers to their reset values and empties the FIFO buffer.
+func (c *Chip) reset() {
+	c.regs = [64]byte{}
+	for reg, val := range resetValues {
+		c.regs[reg] = val
+	}
+	c.fifo = c.fifo[:0]
+	c.crc = 0xFFFF
+	c.cipher = nil
+}
+
+// calcCRC feeds the content of the FIFO buffer to the CRC coprocessor.
+func (c *Chip) calcCRC() {
+	c.crc = crc16(c.crc, c.fifo)
+	c.fifo = c.fifo[:0]
+	c.updateFIFO()
+
+	c.regs[mfrc522.CRCResultLowReg] = byte(c.crc)
+	c.regs[mfrc522.CRCResultHighReg] = byte(c.crc >> 8)
+	c.regs[mfrc522.DivIrqReg] |= crcIRq
+}
+
+// selfTestResult is the result of the digital self-test of a version 2.0 chip
+// (Chapter 16.1.1 of the MFRC55 datasheet).
+var selfTestResult = [fifoSize]byte{
+	0x00, 0xEB, 0x66, 0xBA, 0x57, 0xBF, 0x23, 0x95,
+	0xD0, 0xE3, 0x0D, 0x3D, 0x27, 0x89, 0x5C, 0xDE,
+	0x9D, 0x3B, 0xA7, 0x00, 0x21, 0x5B, 0x89, 0x82,
+	0x51, 0x3A, 0xEB, 0x02, 0x0C, 0xA5, 0x00, 0x49,
+	0x7C, 0x84, 0x4D, 0xB3, 0xCC, 0xD2, 0x1B, 0x81,
+	0x5D, 0x48, 0x76, 0xD5, 0x71, 0x61, 0x21, 0xA9,
+	0x86, 0x96, 0x83, 0x38, 0xCF, 0x9D, 0x5B, 0x6D,
+	0xDC, 0x15, 0xBA, 0x3E, 0x7D, 0x95, 0x3B, 0x2F,
+}
+
+// selfTest fills the FIFO buffer with the result of the digital self-test.
+// The real test depends on the content of the internal buffer, so anything but
+// the cleared buffer changes the result.
+func (c *Chip) selfTest() {
+	c.fifo = append(c.fifo[:0], selfTestResult[:]...)
+	for i, b := range c.buffer {
+		c.fifo[i+1] ^= b
+	}
+	c.updateFIFO()
+}
+
+// crcPreset returns the CRC preset value selected in the ModeReg register.
+func crcPreset(mode byte) uint16 {
+	switch mode & 0x03 {
+	case 0x00:
+		return 0x0000
+	case 0x01:
+		return 0x6363
+	case 0x02:
+		return 0xA671
+	}
+
+	return 0xFFFF
+}
+
+// fieldOn reports whether the RF field is on.
+func (c *Chip) fieldOn() bool {
+	return c.rst && c.regs[mfrc522.CommandReg]&0x10 == 0 && c.regs[mfrc522.TxControlReg]&0x03 != 0
+}
+
+// transmit sends the content of the FIFO buffer to the cards in the RF field
+// and returns their answers.
+func (c *Chip) transmit() []Frame {
+	data := append([]byte(nil), c.fifo...)
+	c.fifo = c.fifo[:0]
+	c.updateFIFO()
+
+	bits := len(data) * 8
+	if last := int(c.regs[mfrc522.BitFramingReg] & 0x07); last != 0 && len(data) > 0 {
+		bits = (len(data)-1)*8 + last
+	} else if c.regs[mfrc522.TxModeReg]&0x80 != 0 {
+		data = AppendCRC(data)
+		bits += 16
+	}
+
+	f := Frame{Data: data, Bits: bits}
+	if c.cipher != nil {
+		f.Parity = c.cipher.Encrypt(f.Data, f.Bits, false)
+	}
+
+	c.regs[mfrc522.ComIrqReg] |= txIRq
+
+	return c.send(f)
+}
+
+// send sends the frame to the cards in the RF field and returns their answers.
+func (c *Chip) send(f Frame) []Frame {
+	if !c.fieldOn() {
+		return nil
+	}
+
+	var res []Frame
+	for _, t := range c.targets {
+		if r := t.Transceive(f); r != nil {
+			res = append(res, *r)
+		}
+	}
+
+	return res
+}
+
+// transceive sends the content of the FIFO buffer and receives the answer into the FIFO buffer.
+func (c *Chip) transceive() {
+	c.regs[mfrc522.ErrorReg] &= bufferOvfl
+	res := c.transmit()
+
+	if len(res) == 0 || c.regs[mfrc522.CommandReg]&0x20 != 0 {
+		if c.regs[mfrc522.TModeReg]&0x80 != 0 {
+			c.regs[mfrc522.ComIrqReg] |= timerIRq
+		}
+
+		return
+	}
+
+	c.receive(res)
+}
+
+// receive combines the answers of all cards and stores them in the FIFO buffer.
+// Bits where the answers differ are collisions, which are reported in the CollReg register.
+func (c *Chip) receive(res []Frame) {
+	align := int(c.regs[mfrc522.BitFramingReg]>>4) & 0x07
+
+	f, coll := c.merge(res)
+	c.regs[mfrc522.CollReg] = c.regs[mfrc522.CollReg]&0x80 | 0x20
+	if coll >= 0 {
+		c.setError(collErr)
+		if pos := align + coll + 1; pos <= 32 {
+			c.regs[mfrc522.CollReg] = c.regs[mfrc522.CollReg]&0x80 | byte(pos)&0x1F
+		}
+	}
+
+	if c.cipher != nil && !c.cipher.Decrypt(f.Data, f.Bits, f.Parity, false) {
+		c.setError(parityErr)
+	}
+
+	data := make([]byte, (align+f.Bits+7)/8)
+	for i := range f.Bits {
+		pos := align + i
+		data[pos/8] |= f.Bit(i) << (pos % 8)
+	}
+
+	if c.regs[mfrc522.RxModeReg]&0x80 != 0 {
+		if (align+f.Bits)%8 != 0 || !CheckCRC(data) {
+			c.setError(crcErr)
+		} else {
+			data = data[:len(data)-2]
+		}
+	}
+
+	for _, b := range data {
+		if len(c.fifo) == fifoSize {
+			c.setError(bufferOvfl)
+			break
+		}
+
+		c.fifo = append(c.fifo, b)
+	}
+	c.updateFIFO()
+
+	c.regs[mfrc522.ControlReg] = c.regs[mfrc522.ControlReg]&0xF8 | byte((align+f.Bits)%8)
+	c.regs[mfrc522.ComIrqReg] |= rxIRq
+}
+
+// merge combines the answers of all cards into one frame, as received by the reader,
+// and returns the position of the first collision, or -1 if there was none.
+func (c *Chip) merge(res []Frame) (Frame, int) {
+	if len(res) == 1 {
+		f := res[0]
+		f.Data = append([]byte(nil), f.Data[:(f.Bits+7)/8]...)
+
+		return f, -1
+	}
+
+	var bits int
+	for _, f := range res {
+		bits = max(bits, f.Bits)
+	}
+
+	merged := Frame{Data: make([]byte, (bits+7)/8), Bits: bits}
+	coll := -1
+	for i := range bits {
+		var ones, zeros int
+		for _, f := range res {
+			if i >= f.Bits {
+				continue
+			}
+			if f.Bit(i) == 1 {
+				ones++
+			} else {
+				zeros++
+			}
+		}
+
+		if ones > 0 && zeros > 0 && coll < 0 {
+			coll = i
+		}
+
+		// ValuesAfterColl selects whether the bits after a collision are kept
+		if coll >= 0 && coll != i && c.regs[mfrc522.CollReg]&0x80 == 0 {
+			continue
+		}
+		if ones > 0 {
+			merged.Data[i/8] |= 1 << (i % 8)
+		}
+	}
+
+	return merged, coll
+}
+
+// authenticate executes the MFAuthent command with the key and UID from the FIFO buffer.
+func (c *Chip) authenticate() {
+	if len(c.fifo) < 12 {
+		c.setError(protocolErr)
+		c.terminate()
+
+		return
+	}
+
+	data := append([]byte(nil), c.fifo[:12]...)
+	c.fifo = c.fifo[:0]
+	c.updateFIFO()
+
+	if c.authenticateCard(data[0], data[1], data[2:8], data[8:12]) {
+		c.regs[mfrc522.Status2Reg] |= mfCrypto1On
+		c.terminate()
+
+		return
+	}
+
+	// The command doesn't terminate if the card doesn't answer
+	if c.regs[mfrc522.TModeReg]&0x80 != 0 {
+		c.regs[mfrc522.ComIrqReg] |= timerIRq
+	}
+}
+
+// authenticateCard runs the three-pass authentication with the card and starts a new Crypto1 session.
+// If a session is already active, the authentication is nested, and the card's nonce is encrypted.
+func (c *Chip) authenticateCard(keyType, block byte, key, uid []byte) bool {
+	req := Frame{Data: AppendCRC([]byte{keyType, block}), Bits: 32}
+	if c.cipher != nil {
+		req.Parity = c.cipher.Encrypt(req.Data, req.Bits, false)
+	}
+
+	nested := c.cipher != nil
+	c.cipher = nil
+	c.regs[mfrc522.Status2Reg] &^= mfCrypto1On
+
+	res := c.send(req)
+	if len(res) != 1 || res[0].Bits != 32 {
+		return false
+	}
+
+	cipher := crypto1.NewCipher(crypto1.Key(key))
+	u := binary.BigEndian.Uint32(uid)
+
+	nt := append([]byte(nil), res[0].Data[:4]...)
+	if nested {
+		for i := range nt {
+			nt[i] ^= cipher.Byte(byte(u>>(24-8*i))^nt[i], true)
+		}
+	} else {
+		cipher.Word(u^binary.BigEndian.Uint32(nt), false)
+	}
+
+	answer := make([]byte, 8)
+	_, _ = rand.Read(answer[:4])
+	binary.BigEndian.PutUint32(answer[4:], crypto1.Successor(binary.BigEndian.Uint32(nt), 64))
+
+	parity := cipher.Encrypt(answer[:4], 32, true)
+	parity = append(parity, cipher.Encrypt(answer[4:], 32, false)...)
+
+	res = c.send(Frame{Data: answer, Bits: 64, Parity: parity})
+	if len(res) != 1 || res[0].Bits != 32 {
+		return false
+	}
+
+	at := append([]byte(nil), res[0].Data[:4]...)
+	if !cipher.Decrypt(at, 32, res[0].Parity, false) ||
+		binary.BigEndian.Uint32(at) != crypto1.Successor(binary.BigEndian.Uint32(nt), 96) {
+		return false
+	}
+
+	c.cipher = cipher
+
+	return true
+}
+
+// setError sets the error bits in the ErrorReg register.
+func (c *Chip) setError(bits byte) {
+	c.regs[mfrc522.ErrorReg] |= bits
+	c.regs[mfrc522.ComIrqReg] |= errIRq
+}
+
+// updateFIFO updates the FIFO level interrupt bits.
+func (c *Chip) updateFIFO() {
+	water := int(c.regs[mfrc522.WaterLevelReg] & 0x3F)
+	if len(c.fifo) <= water {
+		c.regs[mfrc522.ComIrqReg] |= loAlertIRq
+	}
+	if fifoSize-len(c.fifo) <= water {
+		c.regs[mfrc522.ComIrqReg] |= hiAlertIRq
+	}
+}
+
+// status1 returns the value of the Status1Reg register.
+func (c *Chip) status1() byte {
+	var val byte
+	water := int(c.regs[mfrc522.WaterLevelReg] & 0x3F)
+	if len(c.fifo) <= water {
+		val |= 0x01
+	}
+	if fifoSize-len(c.fifo) <= water {
+		val |= 0x02
+	}
+	if c.irqActive() {
+		val |= 0x10
+	}
+	if c.regs[mfrc522.DivIrqReg]&crcIRq != 0 {
+		val |= 0x20
+		if c.crc == 0 {
+			val |= 0x40
+		}
+	}
+
+	return val
+}
+
+// irqActive reports whether any enabled interrupt request is set.
+func (c *Chip) irqActive() bool {
+	com := c.regs[mfrc522.ComIEnReg] & c.regs[mfrc522.ComIrqReg] & 0x7F
+	div := c.regs[mfrc522.DivIEnReg] & c.regs[mfrc522.DivIrqReg] & 0x14
+
+	return com != 0 || div != 0
+}
+
+// irqLevel returns the level of the interrupt pin.
+func (c *Chip) irqLevel() bool {
+	// IRqInv inverts the interrupt pin, so it is low when active
+	return c.irqActive() != (c.regs[mfrc522.ComIEnReg]&0x80 != 0)
+}
+
+// update applies the side effects of an operation on the RF field and the interrupt pin.
+// It returns a function that must be called after unlocking the chip, if not nil.
+func (c *Chip) update() func() {
+	if field := c.fieldOn(); field != c.field {
+		c.field = field
+		if !field {
+			for _, t := range c.targets {
+				t.Reset()
+			}
+		}
+	}
+
+	if irq := c.irqLevel(); irq != c.irq {
+		c.irq = irq
+
+		return c.irqFunc
+	}
+
+	return nil
+}
+
+// irqPin is the interrupt pin of the reader.
+type irqPin struct {
+	c *Chip
+}
+
+// Get returns the level of the interrupt pin.
+func (p irqPin) Get() bool {
+	p.c.mu.Lock()
+	defer p.c.mu.Unlock()
+
+	return p.c.irq
+}
+
+// Set does nothing, since the interrupt pin is an output of the reader.
+func (p irqPin) Set(bool) {}
+
+// SetInterrupt calls callback whenever the level of the interrupt pin changes.
+func (p irqPin) SetInterrupt(callback func()) error {
+	p.c.mu.Lock()
+	defer p.c.mu.Unlock()
+
+	p.c.irqFunc = callback
+
+	return nil
+}
+
+// rstPin is the reset pin of the reader.
+type rstPin struct {
+	c *Chip
+}
+
+// Get returns the level of the reset pin.
+func (p rstPin) Get() bool {
+	p.c.mu.Lock()
+	defer p.c.mu.Unlock()
+
+	return p.c.rst
+}
+
+// Set drives the reset pin. A low level powers down the reader, and a rising edge resets it.
+func (p rstPin) Set(high bool) {
+	p.c.mu.Lock()
+	if high && !p.c.rst {
+		p.c.reset()
+		p.c.buffer = [25]byte{}
+	}
+	p.c.rst = high
+	notify := p.c.update()
+	p.c.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+}
+
+// SetInterrupt is not supported on the reset pin.
+func (p rstPin) SetInterrupt(func()) error {
+	return errors.New("interrupts are not supported on the reset pin")
+}
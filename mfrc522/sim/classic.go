@@ -0,0 +1,879 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"sync"
+
+	"github.com/msthtrifork/gorfid/crypto1"
+	"github.com/msthtrifork/gorfid/mfrc522"
+)
+
+// ClassicType is the memory layout of a MIFARE Classic card.
+type ClassicType int
+
+// MIFARE Classic types
+const (
+	// ClassicMini has 5 sectors of 4 blocks (320 bytes).
+	ClassicMini ClassicType = iota
+
+	// Classic1K has 16 sectors of 4 blocks (1024 bytes).
+	Classic1K
+
+	// Classic4K has 32 sectors of 4 blocks and 8 sectors of 16 blocks (4096 bytes).
+	Classic4K
+)
+
+// Blocks returns the number of blocks on the card.
+func (t ClassicType) Blocks() int {
+	switch t {
+	case ClassicMini:
+		return 20
+	case Classic4K:
+		return 256
+	}
+
+	return 64
+}
+
+// sak returns the SAK of the card type.
+func (t ClassicType) sak() byte {
+	switch t {
+	case ClassicMini:
+		return 0x09
+	case Classic4K:
+		return 0x18
+	}
+
+	return 0x08
+}
+
+// DefaultKey is the key A and key B of a blank card.
+var DefaultKey = []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+// TransportAccessBits are the access bits of a blank card, which allow
+// everything with key A (Section 8.7 of the MIFARE Classic 1K data sheet).
+var TransportAccessBits = []byte{0xFF, 0x07, 0x80, 0x69}
+
+// ACK and NAK values (Section 9.3 of the MIFARE Classic 1K data sheet)
+const (
+	ack          = 0x0A
+	nakInvalidOp = 0x00
+	nakCRC       = 0x01
+)
+
+// classicState is the state of a card (Section 8.5 of the MIFARE Classic 1K data sheet).
+type classicState int
+
+// Card states
+const (
+	stateIdle classicState = iota
+	stateReady
+	stateActive
+	stateAuthenticated
+	stateHalt
+)
+
+// Access permissions
+const (
+	accessNever = 0x00
+	accessKeyA  = 0x01
+	accessKeyB  = 0x02
+	accessBoth  = accessKeyA | accessKeyB
+)
+
+// dataAccess are the read, write, increment and decrement/transfer/restore
+// permissions for data blocks, indexed by the access bits C1 C2 C3.
+var dataAccess = [8][4]byte{
+	{accessBoth, accessBoth, accessBoth, accessBoth},
+	{accessBoth, accessNever, accessNever, accessBoth},
+	{accessBoth, accessNever, accessNever, accessNever},
+	{accessKeyB, accessKeyB, accessNever, accessNever},
+	{accessBoth, accessKeyB, accessNever, accessNever},
+	{accessKeyB, accessNever, accessNever, accessNever},
+	{accessBoth, accessKeyB, accessKeyB, accessBoth},
+	{accessNever, accessNever, accessNever, accessNever},
+}
+
+// trailerAccess are the key A write, access bits read and write, and key B read and write
+// permissions for sector trailers, indexed by the access bits C1 C2 C3.
+var trailerAccess = [8][5]byte{
+	{accessKeyA, accessKeyA, accessNever, accessKeyA, accessKeyA},
+	{accessKeyA, accessKeyA, accessKeyA, accessKeyA, accessKeyA},
+	{accessNever, accessKeyA, accessNever, accessKeyA, accessNever},
+	{accessKeyB, accessBoth, accessKeyB, accessNever, accessKeyB},
+	{accessKeyB, accessBoth, accessNever, accessNever, accessKeyB},
+	{accessNever, accessBoth, accessKeyB, accessNever, accessNever},
+	{accessNever, accessBoth, accessNever, accessNever, accessNever},
+	{accessNever, accessBoth, accessNever, accessNever, accessNever},
+}
+
+// classicAuth is an authentication that is waiting for the reader's answer.
+type classicAuth struct {
+	// cipher is the new Crypto1 session.
+	cipher *crypto1.Cipher
+
+	// nt is the card's nonce.
+	nt uint32
+
+	// sector is the sector being authenticated.
+	sector int
+
+	// keyB is whether key B is used.
+	keyB bool
+
+	// usable is whether the key can be used for authentication.
+	usable bool
+}
+
+// Classic is a MIFARE Classic card that can be placed in the RF field of a Chip.
+// It answers the ISO 14443-3 commands, authenticates with Crypto1 and enforces
+// the access conditions from the sector trailers.
+type Classic struct {
+	mu sync.Mutex
+
+	// typ is the memory layout of the card.
+	typ ClassicType
+
+	// uid is the UID of the card, which is 4, 7 or 10 bytes long.
+	uid []byte
+
+	// blocks holds the memory of the card.
+	blocks [][16]byte
+
+	// state is the current state of the card.
+	state classicState
+
+	// halted is whether the card was woken up from the HALT state.
+	halted bool
+
+	// level is the current cascade level of the anti-collision loop.
+	level int
+
+	// authSector is the authenticated sector.
+	authSector int
+
+	// keyB is whether the sector was authenticated with key B.
+	keyB bool
+
+	// cipher is the Crypto1 session of the authenticated sector.
+	cipher *crypto1.Cipher
+
+	// auth is the authentication in progress, or nil.
+	auth *classicAuth
+
+	// pending is the command waiting for its second part (e.g. data for WRITE).
+	pending byte
+
+	// pendingBlock is the block of the pending command.
+	pendingBlock int
+
+	// value is the internal transfer buffer for value operations.
+	value int32
+
+	// valueAddr is the address byte of the value in the transfer buffer.
+	valueAddr byte
+
+	// valueValid is whether the transfer buffer holds a value.
+	valueValid bool
+
+	// magic is the kind of magic card that is emulated.
+	magic Magic
+
+	// backdoor is the progress of the Gen1a unlock sequence.
+	backdoor backdoorState
+
+	// isoDEP is whether a Gen3 card was activated with RATS and accepts APDUs.
+	isoDEP bool
+
+	// password is the password of a Gen4 card.
+	password [4]byte
+}
+
+// NewClassic creates a blank card with the given UID, which must be 4, 7 or 10 bytes long.
+// All sectors use DefaultKey for both keys and TransportAccessBits.
+func NewClassic(typ ClassicType, uid []byte) (*Classic, error) {
+	if len(uid) != 4 && len(uid) != 7 && len(uid) != 10 {
+		return nil, errors.New("invalid UID length")
+	}
+
+	c := &Classic{typ: typ, uid: append([]byte(nil), uid...), blocks: make([][16]byte, typ.Blocks())}
+	c.writeManufacturerBlock()
+	for sector := range c.sectors() {
+		c.SetTrailer(sector, DefaultKey, TransportAccessBits, DefaultKey)
+	}
+
+	return c, nil
+}
+
+// LoadClassic creates a card from a binary dump of its memory. The type is determined
+// by the size of the dump and the UID is taken from the manufacturer block.
+// A 4-byte UID is assumed if it is followed by a valid BCC, otherwise the UID is 7 bytes long.
+func LoadClassic(r io.Reader) (*Classic, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var typ ClassicType
+	switch len(data) {
+	case ClassicMini.Blocks() * 16:
+		typ = ClassicMini
+	case Classic1K.Blocks() * 16:
+		typ = Classic1K
+	case Classic4K.Blocks() * 16:
+		typ = Classic4K
+	default:
+		return nil, errors.New("invalid dump size")
+	}
+
+	uid := data[:7]
+	if data[0]^data[1]^data[2]^data[3] == data[4] {
+		uid = data[:4]
+	}
+
+	c := &Classic{typ: typ, uid: append([]byte(nil), uid...), blocks: make([][16]byte, typ.Blocks())}
+	for i := range c.blocks {
+		copy(c.blocks[i][:], data[i*16:])
+	}
+
+	return c, nil
+}
+
+// Save writes a binary dump of the memory of the card.
+func (c *Classic) Save(w io.Writer) error {
+	_, err := w.Write(c.Dump())
+
+	return err
+}
+
+// Dump returns the memory of the card.
+func (c *Classic) Dump() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data := make([]byte, 0, len(c.blocks)*16)
+	for _, block := range c.blocks {
+		data = append(data, block[:]...)
+	}
+
+	return data
+}
+
+// UID returns the UID of the card.
+func (c *Classic) UID() []byte {
+	return append([]byte(nil), c.uid...)
+}
+
+// Block returns the content of the block.
+func (c *Classic) Block(block int) [16]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.blocks[block]
+}
+
+// SetBlock sets the content of the block, regardless of the access conditions.
+func (c *Classic) SetBlock(block int, data [16]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.blocks[block] = data
+}
+
+// SetTrailer sets the keys and access bits (including the general purpose byte) of the sector.
+func (c *Classic) SetTrailer(sector int, keyA, access, keyB []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	trailer := &c.blocks[c.trailer(sector)]
+	copy(trailer[0:6], keyA)
+	copy(trailer[6:10], access)
+	copy(trailer[10:16], keyB)
+}
+
+// Reset puts the card into the IDLE state, as if it was taken out of the RF field.
+func (c *Classic) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state = stateIdle
+	c.halted = false
+	c.level = 0
+	c.pending = 0
+	c.valueValid = false
+	c.cipher = nil
+	c.auth = nil
+	c.backdoor = backdoorLocked
+	c.isoDEP = false
+}
+
+// Transceive handles a frame sent by the reader.
+func (c *Classic) Transceive(f Frame) *Frame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if res, ok := c.magicCommand(f); ok {
+		return res
+	}
+
+	// Short frames (REQA and WUPA)
+	if f.Bits == 7 {
+		c.backdoor = backdoorLocked
+		c.isoDEP = false
+		return c.request(f.Data[0] & 0x7F)
+	}
+
+	switch c.state {
+	case stateReady:
+		return c.antiCollision(f)
+	case stateActive, stateAuthenticated:
+	default:
+		return nil
+	}
+
+	if c.auth != nil {
+		return c.authenticate(f)
+	}
+
+	// All frames are encrypted after authentication
+	cipher := c.cipher
+	if cipher != nil {
+		data := append([]byte(nil), f.Data[:(f.Bits+7)/8]...)
+		if !cipher.Decrypt(data, f.Bits, f.Parity, false) {
+			return c.encrypt(cipher, c.nak(nakCRC))
+		}
+		f = Frame{Data: data, Bits: f.Bits}
+	}
+
+	res := c.command(f)
+	if c.auth != nil {
+		// The nonce of a nested authentication is encrypted with the new session
+		return res
+	}
+
+	return c.encrypt(cipher, res)
+}
+
+// encrypt encrypts the answer if the cipher is not nil.
+func (c *Classic) encrypt(cipher *crypto1.Cipher, res *Frame) *Frame {
+	if cipher == nil || res == nil {
+		return res
+	}
+
+	res.Parity = cipher.Encrypt(res.Data, res.Bits, false)
+
+	return res
+}
+
+// startAuthentication handles the AUTH command and returns the card's nonce
+// (Section 10.1 of the MIFARE Classic 1K data sheet).
+func (c *Classic) startAuthentication(keyType byte, block int) *Frame {
+	sector := c.sector(block)
+	trailer := c.blocks[c.trailer(sector)]
+
+	auth := &classicAuth{
+		nt:     crypto1.Successor(rand.Uint32(), 32),
+		sector: sector,
+		keyB:   keyType == mfrc522.AuthKeyBCmd,
+		usable: true,
+	}
+
+	key := trailer[0:6]
+	if auth.keyB {
+		// Key B can't be used for authentication if it is readable
+		key = trailer[10:16]
+		auth.usable = !c.keyBReadable(sector)
+	}
+	auth.cipher = crypto1.NewCipher(crypto1.Key(key))
+
+	uid := binary.BigEndian.Uint32(c.uid[len(c.uid)-4:])
+	nt := binary.BigEndian.AppendUint32(nil, auth.nt)
+	res := NewFrame(append([]byte(nil), nt...)...)
+
+	if c.cipher != nil {
+		// Nested authentication sends the nonce encrypted
+		res.Parity = make([]byte, 4)
+		for i := range nt {
+			res.Data[i] ^= auth.cipher.Byte(byte(uid>>(24-8*i))^nt[i], false)
+			res.Parity[i] = crypto1.Parity(nt[i]) ^ auth.cipher.Filter()
+		}
+	} else {
+		auth.cipher.Word(uid^auth.nt, false)
+	}
+
+	c.cipher = nil
+	c.auth = auth
+
+	return &res
+}
+
+// authenticate checks the reader's answer to the card's nonce and answers with its own.
+func (c *Classic) authenticate(f Frame) *Frame {
+	auth := c.auth
+	c.auth = nil
+
+	data, ok := f.Bytes()
+	if !ok || len(data) != 8 {
+		c.fail()
+		return nil
+	}
+
+	data = append([]byte(nil), data...)
+	var nrParity, arParity []byte
+	if len(f.Parity) == 8 {
+		nrParity, arParity = f.Parity[:4], f.Parity[4:]
+	}
+
+	ok = auth.cipher.Decrypt(data[:4], 32, nrParity, true)
+	ok = auth.cipher.Decrypt(data[4:], 32, arParity, false) && ok
+	if !ok || !auth.usable || binary.BigEndian.Uint32(data[4:]) != crypto1.Successor(auth.nt, 64) {
+		c.fail()
+		return nil
+	}
+
+	c.state = stateAuthenticated
+	c.authSector = auth.sector
+	c.keyB = auth.keyB
+	c.cipher = auth.cipher
+
+	res := NewFrame(binary.BigEndian.AppendUint32(nil, crypto1.Successor(auth.nt, 96))...)
+	res.Parity = c.cipher.Encrypt(res.Data, res.Bits, false)
+
+	return &res
+}
+
+// request handles the REQA and WUPA commands.
+func (c *Classic) request(cmd byte) *Frame {
+	switch {
+	case cmd == mfrc522.WakeUpACmd:
+		c.halted = c.state == stateHalt
+	case cmd != mfrc522.RequestACmd || c.state == stateHalt:
+		return nil
+	}
+
+	c.state = stateReady
+	c.level = 0
+	c.pending = 0
+	c.cipher = nil
+	c.auth = nil
+
+	res := NewFrame(c.atqa()...)
+
+	return &res
+}
+
+// atqa returns the ATQA of the card.
+func (c *Classic) atqa() []byte {
+	atqa := byte(0x04)
+	if c.typ == Classic4K {
+		atqa = 0x02
+	}
+
+	switch len(c.uid) {
+	case 7:
+		atqa |= 0x40
+	case 10:
+		atqa |= 0x80
+	}
+
+	return []byte{atqa, 0x00}
+}
+
+// cascadeLevels returns the number of cascade levels needed for the UID.
+func (c *Classic) cascadeLevels() int {
+	return (len(c.uid) - 1) / 3
+}
+
+// uidPart returns the UID CLn and BCC for the cascade level.
+func (c *Classic) uidPart(level int) []byte {
+	var part []byte
+	if level < c.cascadeLevels()-1 {
+		part = append([]byte{mfrc522.CascadeTagCmd}, c.uid[level*3:level*3+3]...)
+	} else {
+		part = append([]byte(nil), c.uid[level*3:level*3+4]...)
+	}
+
+	return append(part, part[0]^part[1]^part[2]^part[3])
+}
+
+// antiCollision handles the ANTICOLLISION and SELECT commands (ISO 14443-3, section 6.5.3).
+func (c *Classic) antiCollision(f Frame) *Frame {
+	if f.Bits < 16 || f.Data[0] != []byte{mfrc522.AntiCollSelect1Cmd, mfrc522.AntiCollSelect2Cmd,
+		mfrc522.AntiCollSelect3Cmd}[min(c.level, 2)] {
+		c.fail()
+		return nil
+	}
+
+	part := c.uidPart(c.level)
+
+	// SELECT sends the whole UID CLn with CRC
+	if f.Data[1] == 0x70 {
+		data, ok := f.Bytes()
+		if !ok || len(data) != 9 || !CheckCRC(data) || !bytes.Equal(data[2:7], part) {
+			c.fail()
+			return nil
+		}
+
+		sak := c.typ.sak()
+		if c.level++; c.level < c.cascadeLevels() {
+			sak = 0x04
+		} else {
+			c.state = stateActive
+		}
+
+		res := NewFrame(AppendCRC([]byte{sak})...)
+
+		return &res
+	}
+
+	// ANTICOLLISION sends the known bits of the UID CLn, and the card answers with the rest
+	known := int(f.Data[1]>>4-2)*8 + int(f.Data[1]&0x0F)
+	if known < 0 || known >= 40 || f.Bits != 16+known {
+		c.fail()
+		return nil
+	}
+
+	uid := Frame{Data: part, Bits: 40}
+	for i := range known {
+		if f.Bit(16+i) != uid.Bit(i) {
+			// The card doesn't match, so it doesn't take part in this anti-collision loop
+			return nil
+		}
+	}
+
+	res := Frame{Data: make([]byte, (40-known+7)/8), Bits: 40 - known}
+	for i := range res.Bits {
+		res.Data[i/8] |= uid.Bit(known+i) << (i % 8)
+	}
+
+	return &res
+}
+
+// command handles the commands in the ACTIVE and AUTHENTICATED state.
+func (c *Classic) command(f Frame) *Frame {
+	data, ok := f.Bytes()
+	if !ok || len(data) < 3 || !CheckCRC(data) {
+		return c.nak(nakCRC)
+	}
+	data = data[:len(data)-2]
+
+	if c.pending != 0 {
+		return c.pendingCommand(data)
+	}
+
+	switch data[0] {
+	case mfrc522.HaltACmd:
+		if len(data) == 2 && data[1] == 0x00 {
+			c.state = stateHalt
+			c.level = 0
+			c.cipher = nil
+		}
+
+		return nil
+	case mfrc522.AuthKeyACmd, mfrc522.AuthKeyBCmd:
+		if len(data) != 2 || int(data[1]) >= len(c.blocks) {
+			return c.nak(nakInvalidOp)
+		}
+
+		return c.startAuthentication(data[0], int(data[1]))
+	}
+
+	if len(data) != 2 || c.state != stateAuthenticated {
+		return c.nak(nakInvalidOp)
+	}
+
+	block := int(data[1])
+	if block >= len(c.blocks) || c.sector(block) != c.authSector {
+		return c.nak(nakInvalidOp)
+	}
+
+	switch data[0] {
+	case mfrc522.ReadBlockCmd:
+		return c.read(block)
+	case mfrc522.WriteBlockCmd:
+		if block == 0 && c.magic != MagicGen2 || !c.writable(block) {
+			return c.nak(nakInvalidOp)
+		}
+	case mfrc522.IncrementBlockCmd:
+		if !c.allowed(block, 2) || !c.isValue(block) {
+			return c.nak(nakInvalidOp)
+		}
+	case mfrc522.DecrementBlockCmd, mfrc522.RestoreBlockCmd:
+		if !c.allowed(block, 3) || !c.isValue(block) {
+			return c.nak(nakInvalidOp)
+		}
+	case mfrc522.TransferBlockCmd:
+		if !c.valueValid || !c.allowed(block, 3) && !c.allowed(block, 2) {
+			return c.nak(nakInvalidOp)
+		}
+
+		c.writeValue(block)
+
+		return c.ack()
+	default:
+		return c.nak(nakInvalidOp)
+	}
+
+	c.pending = data[0]
+	c.pendingBlock = block
+
+	return c.ack()
+}
+
+// pendingCommand handles the second part of the WRITE and value commands.
+func (c *Classic) pendingCommand(data []byte) *Frame {
+	cmd, block := c.pending, c.pendingBlock
+	c.pending = 0
+
+	if cmd == mfrc522.WriteBlockCmd {
+		if len(data) != 16 {
+			return c.nak(nakInvalidOp)
+		}
+
+		c.write(block, [16]byte(data))
+
+		return c.ack()
+	}
+
+	if len(data) != 4 {
+		return c.nak(nakInvalidOp)
+	}
+
+	value := c.blockValue(block)
+	operand := int32(uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16 | uint32(data[3])<<24)
+	switch cmd {
+	case mfrc522.IncrementBlockCmd:
+		value += operand
+	case mfrc522.DecrementBlockCmd:
+		value -= operand
+	}
+
+	c.value = value
+	c.valueAddr = c.blocks[block][12]
+	c.valueValid = true
+
+	// Value commands don't acknowledge the second part
+	return nil
+}
+
+// read returns the content of the block, with the parts of the sector trailer that can't be read masked out.
+func (c *Classic) read(block int) *Frame {
+	data := c.blocks[block]
+	if block == c.trailer(c.authSector) {
+		// Key A can never be read
+		copy(data[0:6], make([]byte, 6))
+		if !c.trailerAllowed(1) {
+			copy(data[6:10], make([]byte, 4))
+		}
+		if !c.trailerAllowed(3) {
+			copy(data[10:16], make([]byte, 6))
+		}
+	} else if !c.allowed(block, 0) {
+		return c.nak(nakInvalidOp)
+	}
+
+	res := NewFrame(AppendCRC(data[:])...)
+
+	return &res
+}
+
+// writable reports whether the block (or any part of the sector trailer) can be written.
+func (c *Classic) writable(block int) bool {
+	if block != c.trailer(c.authSector) {
+		return c.allowed(block, 1)
+	}
+
+	return c.trailerAllowed(0) || c.trailerAllowed(2) || c.trailerAllowed(4)
+}
+
+// write writes the block, keeping the parts of the sector trailer that can't be written.
+func (c *Classic) write(block int, data [16]byte) {
+	if block == 0 {
+		c.writeBlock0(data)
+		return
+	}
+	if block != c.trailer(c.authSector) {
+		c.blocks[block] = data
+		return
+	}
+
+	trailer := &c.blocks[block]
+	if c.trailerAllowed(0) {
+		copy(trailer[0:6], data[0:6])
+	}
+	if c.trailerAllowed(2) {
+		copy(trailer[6:10], data[6:10])
+	}
+	if c.trailerAllowed(4) {
+		copy(trailer[10:16], data[10:16])
+	}
+}
+
+// writeValue writes the transfer buffer to the block in the value block format.
+func (c *Classic) writeValue(block int) {
+	v := uint32(c.value)
+	value := []byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)}
+
+	var data [16]byte
+	for i := range 4 {
+		data[i] = value[i]
+		data[4+i] = ^value[i]
+		data[8+i] = value[i]
+	}
+	data[12], data[13], data[14], data[15] = c.valueAddr, ^c.valueAddr, c.valueAddr, ^c.valueAddr
+
+	c.blocks[block] = data
+}
+
+// isValue reports whether the block is formatted as a value block.
+func (c *Classic) isValue(block int) bool {
+	data := c.blocks[block]
+	for i := range 4 {
+		if data[i] != data[8+i] || data[i] != ^data[4+i] {
+			return false
+		}
+	}
+
+	return data[12] == data[14] && data[12] == ^data[13] && data[12] == ^data[15]
+}
+
+// blockValue returns the value stored in a value block.
+func (c *Classic) blockValue(block int) int32 {
+	data := c.blocks[block]
+
+	return int32(uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16 | uint32(data[3])<<24)
+}
+
+// accessBits returns the access bits C1 C2 C3 of the block group (0-2 for data, 3 for the trailer)
+// in the sector, and whether the access bits are valid.
+func (c *Classic) accessBits(sector, group int) (byte, bool) {
+	trailer := c.blocks[c.trailer(sector)]
+	b6, b7, b8 := trailer[6], trailer[7], trailer[8]
+	if b6&0x0F != ^b7>>4&0x0F || b6>>4 != ^b8&0x0F || b7&0x0F != ^b8>>4&0x0F {
+		return 0, false
+	}
+
+	c1 := b7 >> (4 + group) & 1
+	c2 := b8 >> group & 1
+	c3 := b8 >> (4 + group) & 1
+
+	return c1<<2 | c2<<1 | c3, true
+}
+
+// allowed reports whether the operation (index into dataAccess) is allowed on the data block
+// with the key used for authentication.
+func (c *Classic) allowed(block, op int) bool {
+	group := c.blockGroup(block)
+	bits, ok := c.accessBits(c.authSector, group)
+	if !ok {
+		return false
+	}
+
+	return c.keyAllowed(dataAccess[bits][op])
+}
+
+// trailerAllowed reports whether the operation (index into trailerAccess) is allowed on the sector trailer
+// with the key used for authentication.
+func (c *Classic) trailerAllowed(op int) bool {
+	bits, ok := c.accessBits(c.authSector, 3)
+	if !ok {
+		return false
+	}
+
+	return c.keyAllowed(trailerAccess[bits][op])
+}
+
+// keyAllowed reports whether the key used for authentication is in the permission.
+func (c *Classic) keyAllowed(access byte) bool {
+	if c.keyB {
+		return access&accessKeyB != 0
+	}
+
+	return access&accessKeyA != 0
+}
+
+// keyBReadable reports whether key B can be read in the sector, in which case it can't be used as a key.
+func (c *Classic) keyBReadable(sector int) bool {
+	bits, ok := c.accessBits(sector, 3)
+
+	return ok && trailerAccess[bits][3] != accessNever
+}
+
+// sectors returns the number of sectors on the card.
+func (c *Classic) sectors() int {
+	if len(c.blocks) > 128 {
+		return 32 + (len(c.blocks)-128)/16
+	}
+
+	return len(c.blocks) / 4
+}
+
+// sector returns the sector of the block.
+func (c *Classic) sector(block int) int {
+	if block < 128 {
+		return block / 4
+	}
+
+	return 32 + (block-128)/16
+}
+
+// trailer returns the sector trailer block of the sector.
+func (c *Classic) trailer(sector int) int {
+	if sector < 32 {
+		return sector*4 + 3
+	}
+
+	return 128 + (sector-32)*16 + 15
+}
+
+// blockGroup returns the access bits group of the block (0-2 for data, 3 for the trailer).
+func (c *Classic) blockGroup(block int) int {
+	if block < 128 {
+		return block % 4
+	}
+
+	return (block - 128) % 16 / 5
+}
+
+// writeManufacturerBlock writes the UID, SAK and ATQA into block 0.
+func (c *Classic) writeManufacturerBlock() {
+	block := &c.blocks[0]
+	n := copy(block[:], c.uid)
+	if len(c.uid) == 4 {
+		block[4] = c.uid[0] ^ c.uid[1] ^ c.uid[2] ^ c.uid[3]
+		n++
+	}
+
+	block[n] = c.typ.sak()
+	copy(block[n+1:], c.atqa())
+}
+
+// ack returns the ACK answer.
+func (c *Classic) ack() *Frame {
+	return &Frame{Data: []byte{ack}, Bits: 4}
+}
+
+// nak returns the NAK answer and resets the card.
+func (c *Classic) nak(code byte) *Frame {
+	if c.valueValid {
+		code |= 0x04
+	}
+	c.fail()
+
+	return &Frame{Data: []byte{code}, Bits: 4}
+}
+
+// fail puts the card back into the IDLE or HALT state after an error.
+func (c *Classic) fail() {
+	c.state = stateIdle
+	if c.halted {
+		c.state = stateHalt
+	}
+	c.level = 0
+	c.pending = 0
+	c.cipher = nil
+}
@@ -0,0 +1,82 @@
+package sim
+
+// Frame is a frame on the RF interface between the reader and a card.
+// Bits are sent starting with the least significant bit of the first byte.
+type Frame struct {
+	// Data holds the bits of the frame.
+	Data []byte
+
+	// Bits is the number of valid bits in Data.
+	Bits int
+
+	// Parity holds the parity bit sent after each whole byte of Data.
+	// It is only needed for encrypted frames, since the parity bits are encrypted as well.
+	// If nil, the parity bits are assumed to be correct.
+	Parity []byte
+}
+
+// NewFrame creates a frame from whole bytes.
+func NewFrame(data ...byte) Frame {
+	return Frame{Data: data, Bits: len(data) * 8}
+}
+
+// Bytes returns the data of the frame if it consists of whole bytes.
+func (f Frame) Bytes() ([]byte, bool) {
+	if f.Bits%8 != 0 || f.Bits/8 > len(f.Data) {
+		return nil, false
+	}
+
+	return f.Data[:f.Bits/8], true
+}
+
+// Bit returns the bit at the given position.
+func (f Frame) Bit(pos int) byte {
+	return (f.Data[pos/8] >> (pos % 8)) & 1
+}
+
+// Target is a card in the reader's RF field.
+type Target interface {
+	// Transceive handles a frame sent by the reader and returns the answer of the card,
+	// or nil if the card doesn't answer.
+	Transceive(f Frame) *Frame
+
+	// Reset is called when the RF field is turned off and the card loses power.
+	Reset()
+}
+
+// CRC calculates the ISO 14443-3 Type A CRC of the data.
+func CRC(data []byte) [2]byte {
+	crc := crc16(0x6363, data)
+
+	return [2]byte{byte(crc), byte(crc >> 8)}
+}
+
+// AppendCRC appends the ISO 14443-3 Type A CRC to the data.
+func AppendCRC(data []byte) []byte {
+	crc := CRC(data)
+
+	return append(data, crc[0], crc[1])
+}
+
+// CheckCRC reports whether the data ends with a valid ISO 14443-3 Type A CRC.
+func CheckCRC(data []byte) bool {
+	if len(data) < 3 {
+		return false
+	}
+
+	crc := CRC(data[:len(data)-2])
+
+	return data[len(data)-2] == crc[0] && data[len(data)-1] == crc[1]
+}
+
+// crc16 calculates the CRC used by ISO 14443, starting with the given preset value.
+func crc16(preset uint16, data []byte) uint16 {
+	crc := preset
+	for _, b := range data {
+		b ^= byte(crc)
+		b ^= b << 4
+		crc = crc>>8 ^ uint16(b)<<8 ^ uint16(b)<<3 ^ uint16(b)>>4
+	}
+
+	return crc
+}
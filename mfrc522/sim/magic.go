@@ -0,0 +1,278 @@
+package sim
+
+import (
+	"github.com/msthtrifork/gorfid/mfrc522"
+)
+
+// Magic is a kind of "magic" card, which is a MIFARE Classic clone that allows changing block 0.
+type Magic int
+
+// Magic card generations
+const (
+	// MagicNone is a genuine card, whose block 0 can't be changed.
+	MagicNone Magic = iota
+
+	// MagicGen1a answers the backdoor commands 0x40 (7 bits) and 0x43, after which
+	// all blocks can be read and written without authentication.
+	MagicGen1a
+
+	// MagicGen2 (CUID) allows writing block 0 with a regular authenticated WRITE.
+	MagicGen2
+
+	// MagicGen3 answers RATS and accepts APDUs that set the UID and write block 0.
+	MagicGen3
+
+	// MagicGen4 (GTU) accepts commands protected by a 4-byte password (00 00 00 00 by default),
+	// which read and write any block without authentication.
+	MagicGen4
+)
+
+// backdoorState is the progress of the Gen1a unlock sequence.
+type backdoorState int
+
+// Backdoor states
+const (
+	backdoorLocked backdoorState = iota
+	backdoorStarted
+	backdoorUnlocked
+)
+
+// Gen1a backdoor commands
+const (
+	gen1aUnlock1 = 0x40
+	gen1aUnlock2 = 0x43
+)
+
+// Gen3 APDU instructions (CLA 0x90)
+const (
+	gen3SetUID      = 0xFB
+	gen3WriteBlock0 = 0xF0
+	gen3Lock        = 0xFD
+)
+
+// Gen4 commands, which follow the prefix 0xCF and the password
+const (
+	gen4Prefix     = 0xCF
+	gen4GetConfig  = 0xC6
+	gen4WriteBlock = 0xCD
+	gen4ReadBlock  = 0xCE
+)
+
+// gen3ATS is the answer to RATS of a Gen3 card.
+var gen3ATS = []byte{0x09, 0x78, 0x00, 0x91, 0x02, 0xDA, 0xBC, 0x19, 0x10}
+
+// gen4Config is the configuration returned by a Gen4 card (MIFARE Classic mode, 4-byte UID).
+var gen4Config = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// SetMagic makes the card behave like a magic card of the given generation.
+func (c *Classic) SetMagic(magic Magic) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.magic = magic
+	c.backdoor = backdoorLocked
+	c.isoDEP = false
+}
+
+// SetPassword sets the password of a Gen4 card.
+func (c *Classic) SetPassword(password [4]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.password = password
+}
+
+// magicCommand handles the commands of magic cards. It reports false if the frame
+// is not a magic command and should be handled as a regular command.
+func (c *Classic) magicCommand(f Frame) (*Frame, bool) {
+	switch c.magic {
+	case MagicGen1a:
+		return c.gen1aCommand(f)
+	case MagicGen3:
+		return c.gen3Command(f)
+	case MagicGen4:
+		return c.gen4Command(f)
+	}
+
+	return nil, false
+}
+
+// gen1aCommand handles the Gen1a unlock sequence and the commands in backdoor mode.
+func (c *Classic) gen1aCommand(f Frame) (*Frame, bool) {
+	switch {
+	case f.Bits == 7 && f.Data[0]&0x7F == gen1aUnlock1:
+		// The card leaves any session and waits for the second part
+		c.fail()
+		c.state = stateHalt
+		c.backdoor = backdoorStarted
+
+		return c.ack(), true
+	case f.Bits == 8 && f.Data[0] == gen1aUnlock2 && c.backdoor == backdoorStarted:
+		c.backdoor = backdoorUnlocked
+
+		return c.ack(), true
+	case c.backdoor == backdoorUnlocked && f.Bits != 7:
+		return c.backdoorCommand(f), true
+	}
+
+	return nil, false
+}
+
+// backdoorCommand handles READ, WRITE and HLTA in the Gen1a backdoor mode,
+// which ignore the authentication and access conditions.
+func (c *Classic) backdoorCommand(f Frame) *Frame {
+	data, ok := f.Bytes()
+	if !ok || len(data) < 3 || !CheckCRC(data) {
+		return c.backdoorNAK()
+	}
+	data = data[:len(data)-2]
+
+	if c.pending == mfrc522.WriteBlockCmd {
+		c.pending = 0
+		if len(data) != 16 {
+			return c.backdoorNAK()
+		}
+
+		c.writeRaw(c.pendingBlock, [16]byte(data))
+
+		return c.ack()
+	}
+
+	if data[0] == mfrc522.HaltACmd {
+		c.backdoor = backdoorLocked
+		c.state = stateHalt
+
+		return nil
+	}
+
+	if len(data) != 2 || int(data[1]) >= len(c.blocks) {
+		return c.backdoorNAK()
+	}
+
+	block := int(data[1])
+	switch data[0] {
+	case mfrc522.ReadBlockCmd:
+		res := NewFrame(AppendCRC(c.blocks[block][:])...)
+		return &res
+	case mfrc522.WriteBlockCmd:
+		c.pending = mfrc522.WriteBlockCmd
+		c.pendingBlock = block
+
+		return c.ack()
+	}
+
+	return c.backdoorNAK()
+}
+
+// backdoorNAK returns a NAK and leaves the backdoor mode.
+func (c *Classic) backdoorNAK() *Frame {
+	c.backdoor = backdoorLocked
+
+	return c.nak(nakInvalidOp)
+}
+
+// gen3Command handles RATS, the Gen3 APDUs and DESELECT of a selected Gen3 card.
+func (c *Classic) gen3Command(f Frame) (*Frame, bool) {
+	data, ok := f.Bytes()
+	if c.state != stateActive || !ok || len(data) < 3 || !CheckCRC(data) {
+		return nil, false
+	}
+	data = data[:len(data)-2]
+
+	switch {
+	case data[0] == mfrc522.RequestAnswerToResetCmd && len(data) == 2:
+		c.isoDEP = true
+
+		res := NewFrame(AppendCRC(append([]byte(nil), gen3ATS...))...)
+		return &res, true
+	case !c.isoDEP:
+		return nil, false
+	case data[0] == mfrc522.DeselectCmd:
+		c.isoDEP = false
+		c.state = stateHalt
+
+		res := NewFrame(AppendCRC([]byte{mfrc522.DeselectCmd})...)
+		return &res, true
+	case data[0]&0xE2 == 0x02:
+		// I-block, which holds an APDU after the PCB
+		sw := c.gen3APDU(data[1:])
+
+		res := NewFrame(AppendCRC([]byte{data[0], sw[0], sw[1]})...)
+		return &res, true
+	}
+
+	return nil, true
+}
+
+// gen3APDU executes a Gen3 APDU and returns the status word.
+func (c *Classic) gen3APDU(apdu []byte) [2]byte {
+	if len(apdu) < 5 || apdu[0] != 0x90 || int(apdu[4]) != len(apdu)-5 {
+		return [2]byte{0x67, 0x00}
+	}
+
+	body := apdu[5:]
+	switch {
+	case apdu[1] == gen3SetUID && apdu[2] == 0xCC && apdu[3] == 0xCC && len(body) == len(c.uid):
+		copy(c.uid, body)
+		c.writeManufacturerBlock()
+	case apdu[1] == gen3WriteBlock0 && apdu[2] == 0xCC && apdu[3] == 0xCC && len(body) == 16:
+		c.writeBlock0([16]byte(body))
+	case apdu[1] == gen3Lock && apdu[2] == 0x11 && apdu[3] == 0x11:
+		c.magic = MagicNone
+	default:
+		return [2]byte{0x6D, 0x00}
+	}
+
+	return [2]byte{0x90, 0x00}
+}
+
+// gen4Command handles the password protected commands of a selected Gen4 card.
+func (c *Classic) gen4Command(f Frame) (*Frame, bool) {
+	data, ok := f.Bytes()
+	if c.state != stateActive || !ok || len(data) < 8 || data[0] != gen4Prefix || !CheckCRC(data) {
+		return nil, false
+	}
+	data = data[:len(data)-2]
+
+	// A wrong password is not answered
+	if [4]byte(data[1:5]) != c.password {
+		return nil, true
+	}
+
+	var res []byte
+	switch {
+	case data[5] == gen4GetConfig && len(data) == 6:
+		res = append([]byte(nil), gen4Config...)
+	case data[5] == gen4ReadBlock && len(data) == 7 && int(data[6]) < len(c.blocks):
+		res = append([]byte(nil), c.blocks[data[6]][:]...)
+	case data[5] == gen4WriteBlock && len(data) == 23 && int(data[6]) < len(c.blocks):
+		c.writeRaw(int(data[6]), [16]byte(data[7:]))
+		res = []byte{0x90, 0x00}
+	default:
+		return nil, true
+	}
+
+	frame := NewFrame(AppendCRC(res)...)
+
+	return &frame, true
+}
+
+// writeRaw writes the block without checking the access conditions.
+func (c *Classic) writeRaw(block int, data [16]byte) {
+	if block == 0 {
+		c.writeBlock0(data)
+		return
+	}
+
+	c.blocks[block] = data
+}
+
+// writeBlock0 writes block 0 of a magic card, which also changes the UID the card answers with.
+func (c *Classic) writeBlock0(data [16]byte) {
+	c.blocks[0] = data
+	copy(c.uid, data[:len(c.uid)])
+}
@@ -0,0 +1,58 @@
+package sim
+
+import (
+	"errors"
+	"io"
+)
+
+// ServeUART answers the register accesses of the reader's UART protocol
+// (Chapter 8.1.3 of the MFRC55 datasheet) on rw, e.g. the other end of a pseudo terminal
+// that is used with mfrc522.UARTBus. It returns when reading from rw fails, or nil at EOF.
+//
+// The baud rate is not simulated: the SerialSpeedReg register can be written and is reset
+// like the others, but the bytes are always exchanged as they are.
+func (c *Chip) ServeUART(rw io.ReadWriter) error {
+	buf := make([]byte, 1)
+	next := func() (byte, error) {
+		if _, err := io.ReadFull(rw, buf); err != nil {
+			return 0, err
+		}
+
+		return buf[0], nil
+	}
+
+	for {
+		addr, err := next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		reg := addr & 0x3F
+		if addr&0x80 != 0 {
+			val, err := c.ReadRegisterBytes(reg, 1)
+			if err != nil {
+				return err
+			}
+			if _, err := rw.Write(val); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		// A write is acknowledged by sending the address back
+		val, err := next()
+		if err != nil {
+			return err
+		}
+		if err := c.WriteRegisterBytes(reg, []byte{val}); err != nil {
+			return err
+		}
+		if _, err := rw.Write([]byte{reg}); err != nil {
+			return err
+		}
+	}
+}
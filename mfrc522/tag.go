@@ -31,6 +31,9 @@ const (
 	// RequestAnswerToResetCmd is a request command for Answer to Reset.
 	RequestAnswerToResetCmd TagCommand = 0xE0
 
+	// DeselectCmd ends an ISO 14443-4 session and puts the tag into the HALT state (ISO 14443-4, section 7.3).
+	DeselectCmd TagCommand = 0xC2
+
 	// Commands for MIFARE Classic (Mifare Classic 1K data sheet, Section 9)
 
 	// AuthKeyACmd is used to authenticate a block using key A.
@@ -56,4 +59,33 @@ const (
 
 	// TransferBlockCmd writes the contents of the internal data register to a block.
 	TransferBlockCmd TagCommand = 0xB0
+
+	// Commands for "Chinese magic" cards (not specified by NXP)
+
+	// Gen1aUnlock1Cmd is the first part of the backdoor unlock sequence, sent as a 7-bit short frame.
+	Gen1aUnlock1Cmd TagCommand = 0x40
+
+	// Gen1aUnlock2Cmd is the second part of the backdoor unlock sequence, sent without a CRC.
+	Gen1aUnlock2Cmd TagCommand = 0x43
+
+	// Gen3 cards accept APDUs with this class byte after RATS.
+	Gen3APDUClass TagCommand = 0x90
+
+	// Gen3SetUIDCmd is the instruction of the APDU that sets the UID of a Gen3 card.
+	Gen3SetUIDCmd TagCommand = 0xFB
+
+	// Gen3WriteBlock0Cmd is the instruction of the APDU that writes block 0 of a Gen3 card.
+	Gen3WriteBlock0Cmd TagCommand = 0xF0
+
+	// Gen4Cmd is the prefix of Gen4 (GTU) commands, which is followed by the 4-byte password.
+	Gen4Cmd TagCommand = 0xCF
+
+	// Gen4GetConfigCmd reads the configuration of a Gen4 card.
+	Gen4GetConfigCmd TagCommand = 0xC6
+
+	// Gen4WriteBlockCmd writes any block of a Gen4 card without authentication.
+	Gen4WriteBlockCmd TagCommand = 0xCD
 )
+
+// cascadeLevels are the anti-collision commands for each cascade level, in order.
+var cascadeLevels = []TagCommand{AntiCollSelect1Cmd, AntiCollSelect2Cmd, AntiCollSelect3Cmd}
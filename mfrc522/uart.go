@@ -0,0 +1,217 @@
+package mfrc522
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// UART is the host's serial interface, which is needed by UARTBus.
+// MachineUART implements it for TinyGo's machine.UART.
+type UART interface {
+	// Write sends the data.
+	Write(data []byte) (int, error)
+
+	// ReadByte waits for the next received byte, and returns an error if none arrived in time.
+	ReadByte() (byte, error)
+
+	// SetBaudRate changes the baud rate of the interface.
+	SetBaudRate(baud uint32) error
+}
+
+// DefaultBaudRate is the baud rate of the reader's UART interface after a reset.
+const DefaultBaudRate = 9600
+
+// serialSpeeds are the values of the SerialSpeedReg register for the baud rates
+// the reader supports (Chapter 8.1.3.2 of the MFRC55 datasheet).
+var serialSpeeds = []struct {
+	baud uint32
+	val  byte
+}{
+	{7200, 0xFA},
+	{9600, 0xEB},
+	{14400, 0xDA},
+	{19200, 0xCB},
+	{38400, 0xAB},
+	{57600, 0x9A},
+	{115200, 0x7A},
+	{128000, 0x74},
+	{230400, 0x5A},
+	{460800, 0x3A},
+	{921600, 0x1C},
+	{1228800, 0x15},
+}
+
+// UARTBus accesses the MFRC522 registers over its UART interface
+// (Chapter 8.1.3 of the MFRC55 datasheet).
+//
+// The reader starts with DefaultBaudRate, which is changed with SetBaudRate.
+// A soft reset sets the reader back to the default rate, so the bus switches the host
+// back as well, and then negotiates the configured rate again.
+type UARTBus struct {
+	// uart is the host's serial interface.
+	uart UART
+
+	// baud is the configured baud rate.
+	baud uint32
+}
+
+// NewUARTBus creates a new UART bus. The host's interface must use DefaultBaudRate,
+// with 8 data bits, no parity and one stop bit.
+func NewUARTBus(uart UART) *UARTBus {
+	return &UARTBus{uart: uart, baud: DefaultBaudRate}
+}
+
+// ReadRegisterBytes reads readLen bytes from the specified register.
+// The reader answers every address byte with the register's content.
+func (b *UARTBus) ReadRegisterBytes(reg Register, readLen int) ([]byte, error) {
+	if readLen < 1 {
+		return nil, nil
+	}
+
+	res := make([]byte, readLen)
+	for i := range res {
+		if _, err := b.uart.Write([]byte{0x80 | reg&0x3F}); err != nil {
+			return nil, err
+		}
+
+		val, err := b.uart.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		res[i] = val
+	}
+
+	return res, nil
+}
+
+// WriteRegisterBytes writes the bytes to the specified register.
+// The reader acknowledges every byte by sending the address back.
+func (b *UARTBus) WriteRegisterBytes(reg Register, val []byte) error {
+	for _, v := range val {
+		if _, err := b.uart.Write([]byte{reg & 0x3F, v}); err != nil {
+			return err
+		}
+
+		// The reset ends with the default baud rate, so the acknowledge is not awaited
+		if reg == CommandReg && v&0x0F == SoftResetCmd {
+			return b.restore()
+		}
+
+		ack, err := b.uart.ReadByte()
+		if err != nil {
+			return err
+		}
+		if ack != reg&0x3F {
+			return wrap("reader didn't acknowledge the write", ErrProtocol)
+		}
+	}
+
+	return nil
+}
+
+// BaudRate returns the configured baud rate.
+func (b *UARTBus) BaudRate() uint32 {
+	return b.baud
+}
+
+// SetBaudRate switches the reader and the host's interface to the baud rate,
+// which must be one of the rates the reader supports (7200 to 1228800, see Chapter 8.1.3.2).
+// Afterwards, the register is read back with the new rate, to check that both switched.
+func (b *UARTBus) SetBaudRate(baud uint32) error {
+	val, ok := serialSpeed(baud)
+	if !ok {
+		return errors.New("unsupported baud rate " + strconv.Itoa(int(baud)))
+	}
+
+	// The reader can't be reached anymore if the host doesn't support the rate after the switch
+	if err := b.uart.SetBaudRate(baud); err != nil {
+		return err
+	}
+	if err := b.uart.SetBaudRate(b.baud); err != nil {
+		return err
+	}
+
+	if err := b.switchRate(val, baud); err != nil {
+		return err
+	}
+
+	b.baud = baud
+
+	return nil
+}
+
+// switchRate writes the SerialSpeedReg value and switches the host to the baud rate.
+func (b *UARTBus) switchRate(val byte, baud uint32) error {
+	if _, err := b.uart.Write([]byte{SerialSpeedReg & 0x3F, val}); err != nil {
+		return err
+	}
+
+	// The acknowledge might already be sent with the new rate, so it is discarded
+	time.Sleep(4 * byteTime(b.baud))
+	if err := b.uart.SetBaudRate(baud); err != nil {
+		return err
+	}
+	b.drain()
+
+	speed, err := b.ReadRegisterBytes(SerialSpeedReg, 1)
+	if err != nil {
+		return err
+	}
+	if speed[0] != val {
+		return wrap("reader didn't switch the baud rate", ErrProtocol)
+	}
+
+	return nil
+}
+
+// restore switches the host back to the default baud rate after a soft reset
+// of the reader, and negotiates the configured rate again.
+func (b *UARTBus) restore() error {
+	time.Sleep(4 * byteTime(b.baud))
+	if err := b.uart.SetBaudRate(DefaultBaudRate); err != nil {
+		return err
+	}
+	b.drain()
+
+	if b.baud == DefaultBaudRate {
+		return nil
+	}
+
+	// The reader keeps the default rate if the switch fails
+	val, _ := serialSpeed(b.baud)
+	baud := b.baud
+	b.baud = DefaultBaudRate
+	if err := b.switchRate(val, baud); err != nil {
+		return err
+	}
+	b.baud = baud
+
+	return nil
+}
+
+// drain discards the received bytes, until no more arrive.
+func (b *UARTBus) drain() {
+	for {
+		if _, err := b.uart.ReadByte(); err != nil {
+			return
+		}
+	}
+}
+
+// serialSpeed returns the SerialSpeedReg value for the baud rate.
+func serialSpeed(baud uint32) (byte, bool) {
+	for _, speed := range serialSpeeds {
+		if speed.baud == baud {
+			return speed.val, true
+		}
+	}
+
+	return 0, false
+}
+
+// byteTime returns the time it takes to send a byte with the baud rate,
+// including the start and stop bits.
+func byteTime(baud uint32) time.Duration {
+	return 10 * time.Second / time.Duration(baud)
+}
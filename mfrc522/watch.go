@@ -0,0 +1,168 @@
+package mfrc522
+
+import (
+	"context"
+	"time"
+)
+
+// Event is a change of the card in the RF field, which is sent by Watch.
+// It is either CardArrived or CardRemoved.
+type Event interface {
+	event()
+}
+
+// CardArrived is sent when a card entered the RF field.
+type CardArrived struct {
+	UID  []byte
+	ATQA uint16
+	SAK  byte
+}
+
+// Card returns the identification of the card that arrived.
+func (e CardArrived) Card() CardInfo {
+	return CardInfo{UUID: e.UID, ATQA: e.ATQA, SAK: e.SAK}
+}
+
+// CardRemoved is sent when the card that arrived left the RF field.
+type CardRemoved struct {
+	UID []byte
+}
+
+func (CardArrived) event() {}
+func (CardRemoved) event() {}
+
+// WatchOptions configure how Watch checks for cards.
+type WatchOptions struct {
+	// Interval is the time between two checks. If zero, 100 ms is used.
+	Interval time.Duration
+
+	// Misses is the number of checks in a row in which the card must be missing,
+	// before it is reported as removed. This debounces a card at the edge of the RF field.
+	// If zero, 3 is used.
+	Misses int
+}
+
+// interval returns the time between two checks.
+func (o WatchOptions) interval() time.Duration {
+	if o.Interval <= 0 {
+		return 100 * time.Millisecond
+	}
+
+	return o.Interval
+}
+
+// misses returns the number of missed checks before a card is removed.
+func (o WatchOptions) misses() int {
+	if o.Misses <= 0 {
+		return 3
+	}
+
+	return o.Misses
+}
+
+// Watch checks the RF field for cards until the context is canceled, and sends an event
+// whenever a card arrives or is removed. The channel is closed when the context is canceled.
+//
+// Watch follows one card at a time: after a card arrived, other cards are ignored until it is removed.
+// While the card is present, each check only wakes it up with WUPA and selects its known UUID,
+// instead of running the anti-collision again, and halts it afterwards.
+// Errors while checking are treated like a missing card.
+//
+// The reader must not be used for anything else until the channel is closed.
+func (m *MFRC522) Watch(ctx context.Context, opts WatchOptions) <-chan Event {
+	events := make(chan Event)
+
+	go func() {
+		defer close(events)
+
+		var card *CardInfo
+		misses := 0
+		for {
+			switch {
+			case card == nil:
+				found, err := m.arrived(ctx)
+				if err != nil {
+					break
+				}
+
+				card = &found
+				misses = 0
+				if !send(ctx, events, CardArrived{UID: found.UUID, ATQA: found.ATQA, SAK: found.SAK}) {
+					return
+				}
+			case m.present(ctx, card.UUID):
+				misses = 0
+			default:
+				misses++
+				if misses < opts.misses() {
+					break
+				}
+
+				uid := card.UUID
+				card = nil
+				if !send(ctx, events, CardRemoved{UID: uid}) {
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(opts.interval()):
+			}
+		}
+	}()
+
+	return events
+}
+
+// arrived wakes up and selects a card in the RF field, and halts it again.
+// It returns ErrNoCard if no card answered.
+func (m *MFRC522) arrived(ctx context.Context) (CardInfo, error) {
+	if err := m.StopCrypto(); err != nil {
+		return CardInfo{}, err
+	}
+
+	// Cards that were halted by an earlier check only answer WUPA
+	atqa, ok, err := m.request(ctx, WakeUpACmd)
+	if err != nil {
+		return CardInfo{}, err
+	}
+	if !ok {
+		return CardInfo{}, ErrNoCard
+	}
+
+	uuid, sak, err := m.cascade(ctx)
+	if err != nil {
+		return CardInfo{}, err
+	}
+
+	_ = m.halt(ctx)
+
+	return CardInfo{UUID: uuid, ATQA: atqa, SAK: sak}, nil
+}
+
+// present reports whether the card with the UUID is still in the RF field.
+// It wakes up the card with WUPA, selects it without the anti-collision and halts it again.
+func (m *MFRC522) present(ctx context.Context, uuid []byte) bool {
+	if _, ok, err := m.request(ctx, WakeUpACmd); err != nil || !ok {
+		return false
+	}
+	if _, err := m.selectKnown(ctx, uuid); err != nil {
+		return false
+	}
+
+	_ = m.halt(ctx)
+
+	return true
+}
+
+// send sends the event, unless the context is canceled first. It reports whether the event was sent.
+func send(ctx context.Context, events chan<- Event, event Event) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,190 @@
+package mfrc522_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/msthtrifork/gorfid/mfrc522"
+	"github.com/msthtrifork/gorfid/mfrc522/sim"
+)
+
+// flakyCard is a card that ignores some WUPA requests, like a card at the edge of the RF field.
+type flakyCard struct {
+	*sim.Classic
+
+	mu sync.Mutex
+
+	// drop is the number of WUPA requests that are still ignored, or -1 to ignore all of them.
+	drop int
+
+	// wakeups and dropped count the WUPA requests that were received and ignored.
+	wakeups, dropped int
+}
+
+// Transceive ignores the WUPA requests that should be dropped, and passes everything else to the card.
+func (f *flakyCard) Transceive(frame sim.Frame) *sim.Frame {
+	f.mu.Lock()
+	if frame.Bits == 7 && frame.Data[0] == mfrc522.WakeUpACmd {
+		f.wakeups++
+		if f.drop != 0 {
+			if f.drop > 0 {
+				f.drop--
+			}
+			f.dropped++
+			f.mu.Unlock()
+
+			return nil
+		}
+	}
+	f.mu.Unlock()
+
+	return f.Classic.Transceive(frame)
+}
+
+// setDrop sets the number of WUPA requests to ignore, and resets the counters.
+func (f *flakyCard) setDrop(drop int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.drop = drop
+	f.wakeups, f.dropped = 0, 0
+}
+
+// counts returns the number of received and ignored WUPA requests.
+func (f *flakyCard) counts() (wakeups, dropped int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.wakeups, f.dropped
+}
+
+// nextEvent waits for the next event from Watch.
+func nextEvent(t *testing.T, events <-chan mfrc522.Event) mfrc522.Event {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("Watch() closed the channel")
+		}
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch() didn't send an event")
+		return nil
+	}
+}
+
+// watch starts Watch, and stops it at the end of the test.
+func watch(t *testing.T, m *mfrc522.MFRC522, opts mfrc522.WatchOptions) <-chan mfrc522.Event {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := m.Watch(ctx, opts)
+	t.Cleanup(func() {
+		cancel()
+		for range events {
+		}
+	})
+
+	return events
+}
+
+func TestWatch(t *testing.T) {
+	card := newCard(t, sim.Classic4K, 0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06)
+	m, c := newReader(t)
+	events := watch(t, m, mfrc522.WatchOptions{Interval: time.Millisecond})
+
+	for range 2 {
+		c.Add(card)
+		arrived, ok := nextEvent(t, events).(mfrc522.CardArrived)
+		if !ok {
+			t.Fatal("first event is not CardArrived")
+		}
+		if !bytes.Equal(arrived.UID, card.UID()) || arrived.Card().Family() != mfrc522.FamilyClassic4K {
+			t.Errorf("CardArrived = % x, %v, want % x, %v",
+				arrived.UID, arrived.Card().Family(), card.UID(), mfrc522.FamilyClassic4K)
+		}
+
+		c.Remove(card)
+		removed, ok := nextEvent(t, events).(mfrc522.CardRemoved)
+		if !ok {
+			t.Fatal("second event is not CardRemoved")
+		}
+		if !bytes.Equal(removed.UID, card.UID()) {
+			t.Errorf("CardRemoved.UID = % x, want % x", removed.UID, card.UID())
+		}
+	}
+}
+
+func TestWatchDebounce(t *testing.T) {
+	card := &flakyCard{Classic: newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)}
+	m, _ := newReader(t, card)
+	events := watch(t, m, mfrc522.WatchOptions{Interval: 10 * time.Millisecond, Misses: 3})
+
+	if _, ok := nextEvent(t, events).(mfrc522.CardArrived); !ok {
+		t.Fatal("first event is not CardArrived")
+	}
+
+	// Two missed checks in a row are not enough to remove the card
+	card.setDrop(2)
+	deadline := time.Now().Add(2 * time.Second)
+	for wakeups, _ := card.counts(); wakeups < 6; wakeups, _ = card.counts() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Watch() stopped checking the card after %d checks", wakeups)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("Watch() sent %T after two missed checks", event)
+	default:
+	}
+
+	card.setDrop(-1)
+	if _, ok := nextEvent(t, events).(mfrc522.CardRemoved); !ok {
+		t.Fatal("event after the card stopped answering is not CardRemoved")
+	}
+	if _, dropped := card.counts(); dropped != 3 {
+		t.Errorf("CardRemoved sent after %d missed checks, want 3", dropped)
+	}
+}
+
+func TestWatchCancel(t *testing.T) {
+	m, _ := newReader(t, newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := m.Watch(ctx, mfrc522.WatchOptions{Interval: time.Millisecond})
+	cancel()
+
+	select {
+	case <-events:
+		// A pending event might still be sent before the channel is closed
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch() didn't stop after the context was canceled")
+	}
+	for range events {
+	}
+}
+
+func TestSelectAfterWatch(t *testing.T) {
+	card := newCard(t, sim.Classic1K, 0xDE, 0xAD, 0xBE, 0xEF)
+	m, _ := newReader(t, card)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := m.Watch(ctx, mfrc522.WatchOptions{Interval: time.Millisecond})
+	if _, ok := nextEvent(t, events).(mfrc522.CardArrived); !ok {
+		t.Fatal("first event is not CardArrived")
+	}
+	cancel()
+	for range events {
+	}
+
+	// Watch leaves the card halted, so it must be woken up to be selected
+	s := selectCard(t, m)
+	if !bytes.Equal(s.UUID(), card.UID()) {
+		t.Errorf("UUID() = % x, want % x", s.UUID(), card.UID())
+	}
+}